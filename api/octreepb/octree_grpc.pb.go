@@ -0,0 +1,401 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             (unknown)
+// source: octree.proto
+
+package octreepb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// OctreeClient is the client API for Octree service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type OctreeClient interface {
+	// Insert adds a new object, the server assigns its id
+	Insert(ctx context.Context, in *InsertRequest, opts ...grpc.CallOption) (*Object, error)
+	// Remove deletes an object by id
+	Remove(ctx context.Context, in *RemoveRequest, opts ...grpc.CallOption) (*RemoveResponse, error)
+	// Move centers an object on a new position, keeping its size
+	Move(ctx context.Context, in *MoveRequest, opts ...grpc.CallOption) (*Object, error)
+	// Get returns an object by id
+	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*Object, error)
+	// GetColliding returns the objects intersecting a box
+	GetColliding(ctx context.Context, in *GetCollidingRequest, opts ...grpc.CallOption) (*Objects, error)
+	// Nearest returns the k objects closest to a point
+	Nearest(ctx context.Context, in *NearestRequest, opts ...grpc.CallOption) (*Objects, error)
+	// Raycast returns the objects crossed by a ray, sorted by distance
+	Raycast(ctx context.Context, in *RaycastRequest, opts ...grpc.CallOption) (*RaycastResponse, error)
+	// Watch streams the changes happening in a region
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (Octree_WatchClient, error)
+}
+
+type octreeClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewOctreeClient(cc grpc.ClientConnInterface) OctreeClient {
+	return &octreeClient{cc}
+}
+
+func (c *octreeClient) Insert(ctx context.Context, in *InsertRequest, opts ...grpc.CallOption) (*Object, error) {
+	out := new(Object)
+	err := c.cc.Invoke(ctx, "/octree.Octree/Insert", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *octreeClient) Remove(ctx context.Context, in *RemoveRequest, opts ...grpc.CallOption) (*RemoveResponse, error) {
+	out := new(RemoveResponse)
+	err := c.cc.Invoke(ctx, "/octree.Octree/Remove", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *octreeClient) Move(ctx context.Context, in *MoveRequest, opts ...grpc.CallOption) (*Object, error) {
+	out := new(Object)
+	err := c.cc.Invoke(ctx, "/octree.Octree/Move", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *octreeClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*Object, error) {
+	out := new(Object)
+	err := c.cc.Invoke(ctx, "/octree.Octree/Get", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *octreeClient) GetColliding(ctx context.Context, in *GetCollidingRequest, opts ...grpc.CallOption) (*Objects, error) {
+	out := new(Objects)
+	err := c.cc.Invoke(ctx, "/octree.Octree/GetColliding", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *octreeClient) Nearest(ctx context.Context, in *NearestRequest, opts ...grpc.CallOption) (*Objects, error) {
+	out := new(Objects)
+	err := c.cc.Invoke(ctx, "/octree.Octree/Nearest", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *octreeClient) Raycast(ctx context.Context, in *RaycastRequest, opts ...grpc.CallOption) (*RaycastResponse, error) {
+	out := new(RaycastResponse)
+	err := c.cc.Invoke(ctx, "/octree.Octree/Raycast", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *octreeClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (Octree_WatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Octree_ServiceDesc.Streams[0], "/octree.Octree/Watch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &octreeWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Octree_WatchClient interface {
+	Recv() (*Event, error)
+	grpc.ClientStream
+}
+
+type octreeWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *octreeWatchClient) Recv() (*Event, error) {
+	m := new(Event)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// OctreeServer is the server API for Octree service.
+// All implementations must embed UnimplementedOctreeServer
+// for forward compatibility
+type OctreeServer interface {
+	// Insert adds a new object, the server assigns its id
+	Insert(context.Context, *InsertRequest) (*Object, error)
+	// Remove deletes an object by id
+	Remove(context.Context, *RemoveRequest) (*RemoveResponse, error)
+	// Move centers an object on a new position, keeping its size
+	Move(context.Context, *MoveRequest) (*Object, error)
+	// Get returns an object by id
+	Get(context.Context, *GetRequest) (*Object, error)
+	// GetColliding returns the objects intersecting a box
+	GetColliding(context.Context, *GetCollidingRequest) (*Objects, error)
+	// Nearest returns the k objects closest to a point
+	Nearest(context.Context, *NearestRequest) (*Objects, error)
+	// Raycast returns the objects crossed by a ray, sorted by distance
+	Raycast(context.Context, *RaycastRequest) (*RaycastResponse, error)
+	// Watch streams the changes happening in a region
+	Watch(*WatchRequest, Octree_WatchServer) error
+	mustEmbedUnimplementedOctreeServer()
+}
+
+// UnimplementedOctreeServer must be embedded to have forward compatible implementations.
+type UnimplementedOctreeServer struct {
+}
+
+func (UnimplementedOctreeServer) Insert(context.Context, *InsertRequest) (*Object, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Insert not implemented")
+}
+func (UnimplementedOctreeServer) Remove(context.Context, *RemoveRequest) (*RemoveResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Remove not implemented")
+}
+func (UnimplementedOctreeServer) Move(context.Context, *MoveRequest) (*Object, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Move not implemented")
+}
+func (UnimplementedOctreeServer) Get(context.Context, *GetRequest) (*Object, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedOctreeServer) GetColliding(context.Context, *GetCollidingRequest) (*Objects, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetColliding not implemented")
+}
+func (UnimplementedOctreeServer) Nearest(context.Context, *NearestRequest) (*Objects, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Nearest not implemented")
+}
+func (UnimplementedOctreeServer) Raycast(context.Context, *RaycastRequest) (*RaycastResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Raycast not implemented")
+}
+func (UnimplementedOctreeServer) Watch(*WatchRequest, Octree_WatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
+func (UnimplementedOctreeServer) mustEmbedUnimplementedOctreeServer() {}
+
+// UnsafeOctreeServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to OctreeServer will
+// result in compilation errors.
+type UnsafeOctreeServer interface {
+	mustEmbedUnimplementedOctreeServer()
+}
+
+func RegisterOctreeServer(s grpc.ServiceRegistrar, srv OctreeServer) {
+	s.RegisterService(&Octree_ServiceDesc, srv)
+}
+
+func _Octree_Insert_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InsertRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OctreeServer).Insert(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/octree.Octree/Insert",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OctreeServer).Insert(ctx, req.(*InsertRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Octree_Remove_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OctreeServer).Remove(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/octree.Octree/Remove",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OctreeServer).Remove(ctx, req.(*RemoveRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Octree_Move_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MoveRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OctreeServer).Move(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/octree.Octree/Move",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OctreeServer).Move(ctx, req.(*MoveRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Octree_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OctreeServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/octree.Octree/Get",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OctreeServer).Get(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Octree_GetColliding_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCollidingRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OctreeServer).GetColliding(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/octree.Octree/GetColliding",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OctreeServer).GetColliding(ctx, req.(*GetCollidingRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Octree_Nearest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NearestRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OctreeServer).Nearest(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/octree.Octree/Nearest",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OctreeServer).Nearest(ctx, req.(*NearestRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Octree_Raycast_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RaycastRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OctreeServer).Raycast(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/octree.Octree/Raycast",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OctreeServer).Raycast(ctx, req.(*RaycastRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Octree_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(OctreeServer).Watch(m, &octreeWatchServer{stream})
+}
+
+type Octree_WatchServer interface {
+	Send(*Event) error
+	grpc.ServerStream
+}
+
+type octreeWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *octreeWatchServer) Send(m *Event) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Octree_ServiceDesc is the grpc.ServiceDesc for Octree service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Octree_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "octree.Octree",
+	HandlerType: (*OctreeServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Insert",
+			Handler:    _Octree_Insert_Handler,
+		},
+		{
+			MethodName: "Remove",
+			Handler:    _Octree_Remove_Handler,
+		},
+		{
+			MethodName: "Move",
+			Handler:    _Octree_Move_Handler,
+		},
+		{
+			MethodName: "Get",
+			Handler:    _Octree_Get_Handler,
+		},
+		{
+			MethodName: "GetColliding",
+			Handler:    _Octree_GetColliding_Handler,
+		},
+		{
+			MethodName: "Nearest",
+			Handler:    _Octree_Nearest_Handler,
+		},
+		{
+			MethodName: "Raycast",
+			Handler:    _Octree_Raycast_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Watch",
+			Handler:       _Octree_Watch_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "octree.proto",
+}
@@ -0,0 +1,45 @@
+// Command octree-server serves a shared Octree over gRPC, see api/octreepb/octree.proto
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"net/http"
+
+	"github.com/louis030195/octree/api/octreepb"
+	octree "github.com/louis030195/octree/pkg"
+	"github.com/louis030195/octree/pkg/grpcserver"
+	"github.com/louis030195/protometry/api/volume"
+	"google.golang.org/grpc"
+)
+
+func main() {
+	addr := flag.String("addr", ":50051", "address to listen on")
+	size := flag.Float64("size", 1000, "size of the cubic region covered by the tree, centered on the origin")
+	capacity := flag.Int("capacity", octree.CAPACITY, "number of objects per node before splitting")
+	metricsAddr := flag.String("metrics", "", "address to serve the Prometheus metrics on, e.g. :9090, disabled by default")
+	flag.Parse()
+
+	lis, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatalf("failed to listen: %v", err)
+	}
+	opts := []octree.Option{octree.WithSplitThreshold(*capacity)}
+	if *metricsAddr != "" {
+		metrics := octree.NewMetrics()
+		opts = append(opts, octree.WithMetrics(metrics))
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics)
+		go func() {
+			log.Fatal(http.ListenAndServe(*metricsAddr, mux))
+		}()
+		log.Printf("metrics served on %v/metrics", *metricsAddr)
+	}
+	s := grpc.NewServer()
+	octreepb.RegisterOctreeServer(s, grpcserver.NewServer(volume.NewBoxOfSize(0, 0, 0, *size), opts...))
+	log.Printf("octree-server listening on %v", lis.Addr())
+	if err := s.Serve(lis); err != nil {
+		log.Fatalf("failed to serve: %v", err)
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	octree "github.com/louis030195/octree/pkg"
+	"github.com/louis030195/protometry/api/vector3"
+	"github.com/louis030195/protometry/api/volume"
+)
+
+// op is a parsed line of an operation log:
+//
+//	insert id minX minY minZ maxX maxY maxZ
+//	remove id
+//	move   id x y z
+//	query  minX minY minZ maxX maxY maxZ
+//	sphere x y z radius
+//	knn    x y z k
+//
+// ids are the ones of the snapshot objects, or labels given by previous inserts.
+type op struct {
+	name   string
+	id     uint64
+	values []float64
+}
+
+// opArity is the number of values expected after the name (and id) of each operation
+var opArity = map[string]struct {
+	id     bool
+	values int
+}{
+	"insert": {true, 6},
+	"remove": {true, 0},
+	"move":   {true, 3},
+	"query":  {false, 6},
+	"sphere": {false, 4},
+	"knn":    {false, 4},
+}
+
+func parseOps(r io.Reader) ([]op, error) {
+	var ops []op
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		arity, ok := opArity[fields[0]]
+		if !ok {
+			return nil, fmt.Errorf("line %v: unknown operation %q", line, fields[0])
+		}
+		o := op{name: fields[0]}
+		fields = fields[1:]
+		expected := arity.values
+		if arity.id {
+			expected++
+		}
+		if len(fields) != expected {
+			return nil, fmt.Errorf("line %v: %v expects %v arguments, got %v", line, o.name, expected, len(fields))
+		}
+		if arity.id {
+			id, err := strconv.ParseUint(fields[0], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %v: %v", line, err)
+			}
+			o.id = id
+			fields = fields[1:]
+		}
+		values, err := parseFloats(fields)
+		if err != nil {
+			return nil, fmt.Errorf("line %v: %v", line, err)
+		}
+		o.values = values
+		ops = append(ops, o)
+	}
+	return ops, scanner.Err()
+}
+
+// benchResult accumulates the timings of one kind of operation
+type benchResult struct {
+	count    int
+	failures int
+	duration time.Duration
+}
+
+func bench(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	snapshot := fs.String("s", "", "snapshot to start from")
+	region := fs.String("region", "", "region of the empty tree to start from when there is no snapshot, minX,minY,minZ,maxX,maxY,maxZ")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || (*snapshot == "") == (*region == "") {
+		return errors.New("usage: octree bench (-s snapshot | -region minX,minY,minZ,maxX,maxY,maxZ) oplog")
+	}
+
+	var o *octree.Octree
+	if *snapshot != "" {
+		var err error
+		if o, err = loadSnapshot(*snapshot); err != nil {
+			return err
+		}
+	} else {
+		r, err := parseVector(*region, 6, 6)
+		if err != nil {
+			return fmt.Errorf("-region %v", err)
+		}
+		o = octree.NewOctree(volume.NewBoxMinMax(r[0], r[1], r[2], r[3], r[4], r[5]))
+	}
+	objects := map[uint64]*octree.Object{}
+	o.Range(func(object *octree.Object) bool {
+		obj := *object
+		objects[obj.ID()] = &obj
+		return true
+	})
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	ops, err := parseOps(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("%v: %v", fs.Arg(0), err)
+	}
+
+	results := map[string]*benchResult{}
+	start := time.Now()
+	for _, op := range ops {
+		res, ok := results[op.name]
+		if !ok {
+			res = &benchResult{}
+			results[op.name] = res
+		}
+		v := op.values
+		opStart := time.Now()
+		success := true
+		switch op.name {
+		case "insert":
+			obj := octree.NewObject(nil, *volume.NewBoxMinMax(v[0], v[1], v[2], v[3], v[4], v[5]))
+			if success = o.Insert(*obj); success {
+				objects[op.id] = obj
+			}
+		case "remove":
+			obj, found := objects[op.id]
+			if success = found && o.Remove(*obj); success {
+				delete(objects, op.id)
+			}
+		case "move":
+			obj, found := objects[op.id]
+			if success = found && o.Move(obj, v[0], v[1], v[2]); !success {
+				// The tree removes the object before failing to insert it at its new position
+				delete(objects, op.id)
+			}
+		case "query":
+			o.GetColliding(*volume.NewBoxMinMax(v[0], v[1], v[2], v[3], v[4], v[5]))
+		case "sphere":
+			o.GetCollidingSphere(*vector3.NewVector3(v[0], v[1], v[2]), v[3])
+		case "knn":
+			o.Nearest(*vector3.NewVector3(v[0], v[1], v[2]), int(v[3]))
+		}
+		res.duration += time.Since(opStart)
+		res.count++
+		if !success {
+			res.failures++
+		}
+	}
+	total := time.Since(start)
+
+	names := make([]string, 0, len(results))
+	for name := range results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(out, "op\tcount\tfailures\ttotal\tns/op\n")
+	for _, name := range names {
+		r := results[name]
+		fmt.Fprintf(out, "%v\t%v\t%v\t%v\t%v\n", name, r.count, r.failures, r.duration, r.duration.Nanoseconds()/int64(r.count))
+	}
+	fmt.Fprintf(out, "total\t%v\t\t%v\n", len(ops), total)
+	return nil
+}
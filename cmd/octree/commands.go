@@ -0,0 +1,289 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	octree "github.com/louis030195/octree/pkg"
+	"github.com/louis030195/protometry/api/vector3"
+	"github.com/louis030195/protometry/api/volume"
+)
+
+func build(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("build", flag.ContinueOnError)
+	output := fs.String("o", "", "snapshot file to write")
+	format := fs.String("format", "", "input format: csv, xyz, ply or pcd, guessed from the extension by default")
+	size := fs.Float64("size", 0, "size of the cube around each point")
+	region := fs.String("region", "", "region covered by the tree, minX,minY,minZ,maxX,maxY,maxZ, the bounding cube of the input by default")
+	capacity := fs.Int("capacity", octree.CAPACITY, "number of objects per node before splitting, kept in the snapshot")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || *output == "" {
+		return errors.New("usage: octree build -o snapshot [flags] file")
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var bounds *volume.Box
+	if *region != "" {
+		r, err := parseVector(*region, 6, 6)
+		if err != nil {
+			return fmt.Errorf("-region %v", err)
+		}
+		bounds = volume.NewBoxMinMax(r[0], r[1], r[2], r[3], r[4], r[5])
+	} else {
+		// A first pass for the bounding cube, the file is streamed twice instead of being held in memory
+		err = load(f, *format, fs.Arg(0), *size, func(obj octree.Object) {
+			if bounds == nil {
+				min, max := *obj.Bounds.Min, *obj.Bounds.Max
+				bounds = &volume.Box{Min: &min, Max: &max}
+			} else {
+				bounds.EncapsulateBox(obj.Bounds)
+			}
+		})
+		if err != nil {
+			return fmt.Errorf("%v: %v", fs.Arg(0), err)
+		}
+		bounds = boundingCube(bounds)
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	}
+	o := octree.NewOctree(bounds, octree.WithSplitThreshold(*capacity))
+	inserted, skipped := 0, 0
+	err = load(f, *format, fs.Arg(0), *size, func(obj octree.Object) {
+		if o.Insert(obj) {
+			inserted++
+		} else {
+			skipped++
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("%v: %v", fs.Arg(0), err)
+	}
+
+	w, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := o.Save(w); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "%v objects inserted, %v outside the region\n", inserted, skipped)
+	return nil
+}
+
+func query(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("query", flag.ContinueOnError)
+	snapshot := fs.String("s", "", "snapshot file to query")
+	box := fs.String("box", "", "objects intersecting minX,minY,minZ,maxX,maxY,maxZ")
+	sphere := fs.String("sphere", "", "objects intersecting the sphere x,y,z,radius")
+	knn := fs.String("knn", "", "k nearest objects from x,y,z,k")
+	ray := fs.String("ray", "", "objects crossed by the ray x,y,z,dx,dy,dz[,maxDistance]")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	o, err := loadSnapshot(*snapshot)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case *box != "":
+		v, err := parseVector(*box, 6, 6)
+		if err != nil {
+			return fmt.Errorf("-box %v", err)
+		}
+		printObjects(out, o.GetColliding(*volume.NewBoxMinMax(v[0], v[1], v[2], v[3], v[4], v[5])))
+	case *sphere != "":
+		v, err := parseVector(*sphere, 4, 4)
+		if err != nil {
+			return fmt.Errorf("-sphere %v", err)
+		}
+		printObjects(out, o.GetCollidingSphere(*vector3.NewVector3(v[0], v[1], v[2]), v[3]))
+	case *knn != "":
+		v, err := parseVector(*knn, 4, 4)
+		if err != nil {
+			return fmt.Errorf("-knn %v", err)
+		}
+		printObjects(out, o.Nearest(*vector3.NewVector3(v[0], v[1], v[2]), int(v[3])))
+	case *ray != "":
+		v, err := parseVector(*ray, 6, 7)
+		if err != nil {
+			return fmt.Errorf("-ray %v", err)
+		}
+		maxDistance := 0.
+		if len(v) == 7 {
+			maxDistance = v[6]
+		}
+		for _, h := range o.Raycast(*vector3.NewVector3(v[0], v[1], v[2]), *vector3.NewVector3(v[3], v[4], v[5]), maxDistance) {
+			fmt.Fprintf(out, "%v\t%v\t%v\n", h.Object.ID(), formatBox(h.Object.Bounds), h.Distance)
+		}
+	default:
+		return errors.New("usage: octree query -s snapshot (-box ... | -sphere ... | -knn ... | -ray ...)")
+	}
+	return nil
+}
+
+func stats(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	snapshot := fs.String("s", "", "snapshot file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	o, err := loadSnapshot(*snapshot)
+	if err != nil {
+		return err
+	}
+
+	s := o.Stats()
+	fmt.Fprintf(out, "height:      %v\n", s.Height)
+	fmt.Fprintf(out, "nodes:       %v\n", s.Nodes)
+	fmt.Fprintf(out, "leaves:      %v (%v empty)\n", s.Leaves, s.EmptyLeaves)
+	fmt.Fprintf(out, "objects:     %v\n", s.Objects)
+	fmt.Fprintf(out, "straddling:  %v\n", s.Straddling)
+	fmt.Fprintf(out, "max objects: %v\n", s.MaxObjects)
+	fmt.Fprintf(out, "usage:       %.4f\n", s.Usage)
+	fmt.Fprintf(out, "memory:      %v bytes\n", s.Bytes)
+	fmt.Fprintf(out, "depth\tnodes\tobjects\n")
+	for i := range s.NodesPerDepth {
+		fmt.Fprintf(out, "%v\t%v\t%v\t%v\n", i, s.NodesPerDepth[i], s.ObjectsPerDepth[i], bar(s.NodesPerDepth[i], s.Nodes))
+	}
+	return nil
+}
+
+func export(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	snapshot := fs.String("s", "", "snapshot file")
+	output := fs.String("o", "", "file to write, OBJ or glTF depending on the extension")
+	color := fs.String("color", "depth", "color of the nodes: depth or occupancy")
+	objects := fs.Bool("objects", false, "also export the object bounds")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	opts := octree.ExportOptions{Objects: *objects}
+	switch *color {
+	case "depth":
+	case "occupancy":
+		opts.Color = octree.ColorByOccupancy
+	default:
+		return fmt.Errorf("-color %q: expected depth or occupancy", *color)
+	}
+	exporter := (*octree.Octree).ExportOBJ
+	switch strings.ToLower(filepath.Ext(*output)) {
+	case ".obj":
+	case ".gltf":
+		exporter = (*octree.Octree).ExportGLTF
+	default:
+		return errors.New("usage: octree export -s snapshot -o file.obj|file.gltf [flags]")
+	}
+	o, err := loadSnapshot(*snapshot)
+	if err != nil {
+		return err
+	}
+
+	w, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := exporter(o, w, opts); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "%v nodes written to %v\n", len(o.GetNodes()), *output)
+	return nil
+}
+
+func render(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("render", flag.ContinueOnError)
+	snapshot := fs.String("s", "", "snapshot file")
+	output := fs.String("o", "", "image to write, SVG or PNG depending on the extension")
+	axis := fs.String("axis", "z", "normal of the slice plane: x, y or z")
+	at := fs.Float64("at", 0, "coordinate of the slice plane along the axis")
+	width := fs.Int("width", 512, "image width in pixels")
+	height := fs.Int("height", 512, "image height in pixels")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	axes := map[string]octree.Axis{"x": octree.AxisX, "y": octree.AxisY, "z": octree.AxisZ}
+	a, ok := axes[strings.ToLower(*axis)]
+	if !ok {
+		return fmt.Errorf("-axis %q: expected x, y or z", *axis)
+	}
+	ext := strings.ToLower(filepath.Ext(*output))
+	if ext != ".svg" && ext != ".png" {
+		return errors.New("usage: octree render -s snapshot -o file.svg|file.png [flags]")
+	}
+	o, err := loadSnapshot(*snapshot)
+	if err != nil {
+		return err
+	}
+
+	s := o.RenderSlice(a, *at, *width, *height)
+	w, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if ext == ".svg" {
+		_, err = io.WriteString(w, s.SVG())
+	} else {
+		err = s.WritePNG(w)
+	}
+	if err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "slice written to %v\n", *output)
+	return nil
+}
+
+func loadSnapshot(path string) (*octree.Octree, error) {
+	if path == "" {
+		return nil, errors.New("missing snapshot, -s")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	o, err := octree.Load(f)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	return o, nil
+}
+
+func printObjects(out io.Writer, objects []octree.Object) {
+	for _, obj := range objects {
+		fmt.Fprintf(out, "%v\t%v\n", obj.ID(), formatBox(obj.Bounds))
+	}
+}
+
+func formatBox(b volume.Box) string {
+	return fmt.Sprintf("%v,%v,%v,%v,%v,%v", b.Min.X, b.Min.Y, b.Min.Z, b.Max.X, b.Max.Y, b.Max.Z)
+}
+
+// bar is a 40 characters wide histogram bar of n over total
+func bar(n, total int) string {
+	return strings.Repeat("#", int(math.Ceil(40*float64(n)/float64(total))))
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	octree "github.com/louis030195/octree/pkg"
+	"github.com/louis030195/octree/pkg/pointcloud"
+	"github.com/louis030195/protometry/api/volume"
+)
+
+// load streams the objects of a file to f, points become cubes of the given size.
+// format is guessed from the extension when empty. The attributes of the
+// xyz, ply and pcd points (colors, normals...) are kept as the objects data.
+func load(r io.Reader, format, name string, size float64, f func(octree.Object)) error {
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(name)), ".")
+	}
+	var reader pointcloud.Reader
+	var err error
+	switch format {
+	case "csv":
+		return loadCSV(r, size, f)
+	case "xyz", "txt":
+		reader = pointcloud.NewXYZReader(r)
+	case "ply":
+		reader, err = pointcloud.NewPLYReader(r)
+	case "pcd":
+		reader, err = pointcloud.NewPCDReader(r)
+	default:
+		return fmt.Errorf("unknown format %q, expected csv, xyz, ply or pcd", format)
+	}
+	if err != nil {
+		return err
+	}
+	for {
+		p, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		f(*pointcloud.NewObject(p, size))
+	}
+}
+
+// loadCSV streams rows of x,y,z points or minX,minY,minZ,maxX,maxY,maxZ boxes,
+// a non numeric first row is considered as a header
+func loadCSV(r io.Reader, size float64, f func(octree.Object)) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		values, err := parseFloats(record)
+		if err != nil {
+			if line == 1 {
+				continue
+			}
+			return fmt.Errorf("line %v: %v", line, err)
+		}
+		switch len(values) {
+		case 3:
+			f(*octree.NewObjectCube(nil, values[0], values[1], values[2], size))
+		case 6:
+			f(*octree.NewObject(nil, *volume.NewBoxMinMax(values[0], values[1], values[2], values[3], values[4], values[5])))
+		default:
+			return fmt.Errorf("line %v: expected 3 or 6 columns, got %v", line, len(values))
+		}
+	}
+}
+
+func parseFloats(fields []string) ([]float64, error) {
+	values := make([]float64, len(fields))
+	for i, f := range fields {
+		v, err := strconv.ParseFloat(strings.TrimSpace(f), 64)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// parseVector parses comma separated floats, expecting between min and max values
+func parseVector(s string, min, max int) ([]float64, error) {
+	values, err := parseFloats(strings.Split(s, ","))
+	if err != nil {
+		return nil, err
+	}
+	if len(values) < min || len(values) > max {
+		return nil, fmt.Errorf("%q: expected between %v and %v values, got %v", s, min, max, len(values))
+	}
+	return values, nil
+}
+
+// boundingCube returns the smallest cube containing bounds, slightly inflated
+// so the objects touching the borders still fit after float rounding, a unit cube without bounds
+func boundingCube(bounds *volume.Box) *volume.Box {
+	if bounds == nil {
+		return volume.NewBoxOfSize(0, 0, 0, 1)
+	}
+	s := bounds.GetSize()
+	size := s.X
+	if s.Y > size {
+		size = s.Y
+	}
+	if s.Z > size {
+		size = s.Z
+	}
+	if size == 0 {
+		size = 1
+	}
+	c := bounds.GetCenter()
+	return volume.NewBoxOfSize(c.X, c.Y, c.Z, size*1.01)
+}
@@ -0,0 +1,59 @@
+// Command octree builds trees from point clouds or box lists, and queries them.
+//
+// Usage:
+//
+//	octree build -o snapshot [-format csv|xyz|ply|pcd] [-size s] [-region minX,minY,minZ,maxX,maxY,maxZ] file
+//	octree query -s snapshot (-box minX,minY,minZ,maxX,maxY,maxZ | -sphere x,y,z,r | -knn x,y,z,k | -ray x,y,z,dx,dy,dz[,max])
+//	octree stats -s snapshot
+//	octree export -s snapshot -o file.obj|file.gltf [-color depth|occupancy] [-objects]
+//	octree render -s snapshot -o file.svg|file.png [-axis x|y|z] [-at coordinate] [-width w] [-height h]
+//	octree bench [-s snapshot | -region minX,minY,minZ,maxX,maxY,maxZ] oplog
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+const usage = `usage: octree <command> [arguments]
+
+commands:
+  build   load points or boxes from a CSV, XYZ, PLY or PCD file and save a snapshot
+  query   run a box, sphere, k-NN or ray query against a snapshot
+  stats   print the height, node count, usage and depth histogram of a snapshot
+  export  write the nodes of a snapshot as wireframe cubes in an OBJ or glTF file
+  render  draw a cross section of a snapshot as an SVG or PNG image
+  bench   replay an operation log and print timings
+
+run 'octree <command> -h' for the command arguments
+`
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	switch args[0] {
+	case "build":
+		return build(args[1:], out)
+	case "query":
+		return query(args[1:], out)
+	case "stats":
+		return stats(args[1:], out)
+	case "export":
+		return export(args[1:], out)
+	case "render":
+		return render(args[1:], out)
+	case "bench":
+		return bench(args[1:], out)
+	}
+	return fmt.Errorf("unknown command %q\n%v", args[0], usage)
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	octree "github.com/louis030195/octree/pkg"
+	"github.com/louis030195/octree/pkg/pointcloud"
+)
+
+// equals fails the test if exp is not equal to act.
+func equals(tb testing.TB, exp, act interface{}) {
+	tb.Helper()
+	if !reflect.DeepEqual(exp, act) {
+		tb.Fatalf("\n\texp: %#v\n\n\tgot: %#v", exp, act)
+	}
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "octree")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func runOK(t *testing.T, args ...string) string {
+	t.Helper()
+	var out bytes.Buffer
+	if err := run(args, &out); err != nil {
+		t.Fatalf("octree %v: %v", strings.Join(args, " "), err)
+	}
+	return out.String()
+}
+
+func TestLoad(t *testing.T) {
+	inputs := map[string]string{
+		"points.csv": "x,y,z\n0,0,0\n1,1,1\n2,2,2\n",
+		"boxes.csv":  "0,0,0,1,1,1\n-1,-1,-1,0,0,0\n2,2,2,3,3,3\n",
+		"points.xyz": "# comment\n0 0 0 255 0 0\n1 1 1\n\n2 2 2\n",
+		"points.ply": `ply
+format ascii 1.0
+element vertex 3
+property float z
+property float y
+property float x
+property uchar red
+element face 1
+property list uchar int vertex_indices
+end_header
+0 0 0 1
+1 1 1 2
+2 2 2 3
+3 0 1 2
+`,
+		"points.pcd": "FIELDS x y z intensity\nSIZE 4 4 4 4\nTYPE F F F F\nPOINTS 3\nDATA ascii\n0 0 0 1\n1 1 1 2\n2 2 2 3\n",
+	}
+	// The data of the last object
+	data := map[string]interface{}{
+		"points.csv": nil,
+		"boxes.csv":  nil,
+		"points.xyz": pointcloud.Attributes{},
+		"points.ply": pointcloud.Attributes{"red": 3},
+		"points.pcd": pointcloud.Attributes{"intensity": 3},
+	}
+	for name, content := range inputs {
+		var objects []octree.Object
+		err := load(strings.NewReader(content), "", name, 0.5, func(obj octree.Object) {
+			objects = append(objects, obj)
+		})
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		equals(t, 3, len(objects))
+		equals(t, data[name], objects[2].Data)
+	}
+
+	ignore := func(octree.Object) {}
+	if err := load(strings.NewReader("0,0\n"), "csv", "", 0, ignore); err == nil {
+		t.Fatal("expected an error on 2 columns")
+	}
+	if err := load(strings.NewReader("ply\nformat binary_little_endian 1.0\n"), "ply", "", 0, ignore); err == nil {
+		t.Fatal("expected an error on a truncated PLY")
+	}
+	if err := load(strings.NewReader(""), "las", "", 0, ignore); err == nil {
+		t.Fatal("expected an error on unknown format")
+	}
+}
+
+func TestBuildQueryStats(t *testing.T) {
+	dir := tempDir(t)
+	var points strings.Builder
+	for i := 0; i < 100; i++ {
+		points.WriteString(strings.Repeat(string('0'+rune(i%10))+" ", 3) + "\n")
+	}
+	input := writeFile(t, dir, "points.xyz", points.String())
+	snapshot := filepath.Join(dir, "points.octree")
+
+	out := runOK(t, "build", "-o", snapshot, "-size", "0.5", input)
+	if !strings.HasPrefix(out, "100 objects inserted") {
+		t.Fatalf("unexpected build output %q", out)
+	}
+
+	out = runOK(t, "query", "-s", snapshot, "-box", "-0.5,-0.5,-0.5,0.5,0.5,0.5")
+	if n := strings.Count(out, "\n"); n != 10 {
+		t.Fatalf("expected 10 objects at the origin, got %v", n)
+	}
+	out = runOK(t, "query", "-s", snapshot, "-sphere", "9,9,9,0.1")
+	if n := strings.Count(out, "\n"); n != 10 {
+		t.Fatalf("expected 10 objects at 9,9,9 got %v", n)
+	}
+	out = runOK(t, "query", "-s", snapshot, "-knn", "5,5,5,3")
+	if n := strings.Count(out, "\n"); n != 3 {
+		t.Fatalf("expected 3 nearest objects, got %v", n)
+	}
+	out = runOK(t, "query", "-s", snapshot, "-ray", "-5,0,0,1,0,0,10")
+	if n := strings.Count(out, "\n"); n != 10 {
+		t.Fatalf("expected the ray to cross 10 objects, got %v", n)
+	}
+
+	out = runOK(t, "stats", "-s", snapshot)
+	if !strings.Contains(out, "objects:     100\n") {
+		t.Fatalf("unexpected stats %q", out)
+	}
+	// The capacity is kept in the snapshot, a larger one gives fewer nodes
+	o, err := loadSnapshot(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes := o.Stats().Nodes
+	runOK(t, "build", "-o", snapshot, "-size", "0.5", "-capacity", "100", input)
+	if o, err = loadSnapshot(snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if o.Stats().Nodes != 1 || nodes == 1 {
+		t.Fatalf("expected a single node with a capacity of 100 instead of %v, got %v", nodes, o.Stats().Nodes)
+	}
+
+	for _, name := range []string{"points.obj", "points.gltf"} {
+		runOK(t, "export", "-s", snapshot, "-o", filepath.Join(dir, name), "-color", "occupancy", "-objects")
+		if info, err := os.Stat(filepath.Join(dir, name)); err != nil || info.Size() == 0 {
+			t.Fatalf("%v not exported: %v", name, err)
+		}
+	}
+	for _, name := range []string{"slice.svg", "slice.png"} {
+		runOK(t, "render", "-s", snapshot, "-o", filepath.Join(dir, name), "-axis", "y", "-at", "5", "-width", "64", "-height", "64")
+		if info, err := os.Stat(filepath.Join(dir, name)); err != nil || info.Size() == 0 {
+			t.Fatalf("%v not rendered: %v", name, err)
+		}
+	}
+	if err := run([]string{"export", "-s", snapshot, "-o", "points.stl"}, ioutil.Discard); err == nil {
+		t.Fatal("expected an error on unknown export format")
+	}
+
+	if err := run([]string{"query", "-s", snapshot}, ioutil.Discard); err == nil {
+		t.Fatal("expected an error without query")
+	}
+	if err := run([]string{"nope"}, ioutil.Discard); err == nil {
+		t.Fatal("expected an error on unknown command")
+	}
+}
+
+func TestBench(t *testing.T) {
+	dir := tempDir(t)
+	log := writeFile(t, dir, "ops.log", `# a small session
+insert 1 0 0 0 1 1 1
+insert 2 5 5 5 6 6 6
+move 1 3 3 3
+move 2 50 50 50
+query 0 0 0 10 10 10
+sphere 0 0 0 5
+knn 0 0 0 1
+remove 2
+remove 2
+`)
+	out := runOK(t, "bench", "-region", "-10,-10,-10,10,10,10", log)
+	for _, line := range []string{"insert\t2\t0\t", "remove\t2\t2\t", "move\t2\t1\t", "total\t9\t"} {
+		if !strings.Contains(out, line) {
+			t.Fatalf("expected %q in %q", line, out)
+		}
+	}
+
+	bad := writeFile(t, dir, "bad.log", "teleport 1 0 0 0\n")
+	if err := run([]string{"bench", "-region", "-10,-10,-10,10,10,10", bad}, ioutil.Discard); err == nil {
+		t.Fatal("expected an error on unknown operation")
+	}
+}
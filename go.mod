@@ -4,4 +4,6 @@ go 1.14
 
 require (
 	github.com/louis030195/protometry v0.2.0
+	google.golang.org/grpc v1.43.0
+	google.golang.org/protobuf v1.27.1
 )
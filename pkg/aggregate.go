@@ -0,0 +1,177 @@
+package octree
+
+import (
+	"math"
+
+	"github.com/louis030195/protometry/api/volume"
+)
+
+// Aggregator summarizes a set of objects in a value, e.g. their count or their total mass.
+// The nodes of a tree created WithAggregator keep the value of their subtree up to date,
+// so range aggregations use whole nodes instead of listing their objects, see AggregateInBox.
+// Aggregators are looked up by equality, they must be comparable, e.g. pointers.
+// RebuildAsync calls Object in the background on copies sharing the Data of the objects,
+// it must not read Data the callers modify meanwhile
+type Aggregator interface {
+	// Empty returns the value of no object
+	Empty() interface{}
+	// Object returns the value of a single object
+	Object(object *Object) interface{}
+	// Merge combines two values, it must be associative and commutative
+	Merge(a, b interface{}) interface{}
+}
+
+// WithAggregator maintains the value of a in every node of the tree, several aggregators can be given
+func WithAggregator(a Aggregator) Option {
+	return func(o *Octree) {
+		o.aggregators = append(o.aggregators, a)
+	}
+}
+
+// getAggregators returns the aggregators of the tree, nil for nodes built outside NewOctree
+func (o *Octree) getAggregators() []Aggregator {
+	if o == nil {
+		return nil
+	}
+	return o.aggregators
+}
+
+// aggregatorIndex returns the index of a in the aggregates of the nodes, -1 if the tree doesn't maintain it
+func (o *Octree) aggregatorIndex(a Aggregator) int {
+	for i := range o.aggregators {
+		if o.aggregators[i] == a {
+			return i
+		}
+	}
+	return -1
+}
+
+// Aggregate returns the value of a for all the objects of the tree, nil if a wasn't given to WithAggregator
+func (o *Octree) Aggregate(a Aggregator) interface{} {
+	i := o.aggregatorIndex(a)
+	if i < 0 {
+		return nil
+	}
+	return o.root.aggregates[i]
+}
+
+// AggregateInBox returns the value of a for the objects that intersect with the specified bounds,
+// the same as aggregating GetColliding(bounds) without listing the objects of the nodes fitting inside bounds.
+// It returns nil if a wasn't given to WithAggregator
+func (o *Octree) AggregateInBox(a Aggregator, bounds volume.Box) interface{} {
+	i := o.aggregatorIndex(a)
+	if i < 0 {
+		return nil
+	}
+	return o.root.aggregateInBox(a, i, bounds, a.Empty())
+}
+
+// aggregateInBox merges into value the value of a, at index i in the aggregates, for the objects
+// of the subtree intersecting bounds
+func (n *Node) aggregateInBox(a Aggregator, i int, bounds volume.Box, value interface{}) interface{} {
+	n.metrics().add(countVisits)
+	if n.region.Fit(bounds) {
+		return a.Merge(value, n.aggregates[i])
+	}
+	if !n.region.Intersects(bounds) {
+		return value
+	}
+	for j := range n.objects {
+		if n.objects[j].Bounds.Intersects(bounds) {
+			value = a.Merge(value, a.Object(&n.objects[j]))
+		}
+	}
+	if n.children != nil {
+		for j := range n.children {
+			value = n.children[j].aggregateInBox(a, i, bounds, value)
+		}
+	}
+	return value
+}
+
+// addAggregates merges the value of object into the aggregates of n
+func (n *Node) addAggregates(object *Object) {
+	for i, a := range n.tree.getAggregators() {
+		n.aggregates[i] = a.Merge(n.aggregates[i], a.Object(object))
+	}
+}
+
+// updateAggregates recomputes the aggregates of n from its objects and its children
+func (n *Node) updateAggregates() {
+	n.aggregate(n.tree.getAggregators())
+}
+
+// aggregate recomputes the values of aggregators, the ones of the tree, in n from its objects and its children
+func (n *Node) aggregate(aggregators []Aggregator) {
+	if len(aggregators) == 0 {
+		return
+	}
+	if n.aggregates == nil {
+		n.aggregates = make([]interface{}, len(aggregators))
+	}
+	for i, a := range aggregators {
+		value := a.Empty()
+		for j := range n.objects {
+			value = a.Merge(value, a.Object(&n.objects[j]))
+		}
+		if n.children != nil {
+			for j := range n.children {
+				value = a.Merge(value, n.children[j].aggregates[i])
+			}
+		}
+		n.aggregates[i] = value
+	}
+}
+
+// CountAggregator aggregates the number of objects, as an int
+type CountAggregator struct{}
+
+// Empty implements Aggregator
+func (CountAggregator) Empty() interface{} { return 0 }
+
+// Object implements Aggregator
+func (CountAggregator) Object(*Object) interface{} { return 1 }
+
+// Merge implements Aggregator
+func (CountAggregator) Merge(a, b interface{}) interface{} { return a.(int) + b.(int) }
+
+// SumAggregator aggregates the sum of Value over the objects, as a float64, e.g. their total mass.
+// Use it as a pointer, &SumAggregator{...}
+type SumAggregator struct {
+	Value func(*Object) float64
+}
+
+// Empty implements Aggregator
+func (s *SumAggregator) Empty() interface{} { return 0. }
+
+// Object implements Aggregator
+func (s *SumAggregator) Object(object *Object) interface{} { return s.Value(object) }
+
+// Merge implements Aggregator
+func (s *SumAggregator) Merge(a, b interface{}) interface{} { return a.(float64) + b.(float64) }
+
+// MinMax is the range of values aggregated by MinMaxAggregator, Min > Max when there is no object
+type MinMax struct {
+	Min, Max float64
+}
+
+// MinMaxAggregator aggregates the minimum and the maximum of Value over the objects, as a MinMax.
+// Use it as a pointer, &MinMaxAggregator{...}
+type MinMaxAggregator struct {
+	Value func(*Object) float64
+}
+
+// Empty implements Aggregator
+func (m *MinMaxAggregator) Empty() interface{} { return MinMax{Min: math.Inf(1), Max: math.Inf(-1)} }
+
+// Object implements Aggregator
+func (m *MinMaxAggregator) Object(object *Object) interface{} {
+	v := m.Value(object)
+	return MinMax{Min: v, Max: v}
+}
+
+// Merge implements Aggregator
+func (m *MinMaxAggregator) Merge(a, b interface{}) interface{} {
+	x, y := a.(MinMax), b.(MinMax)
+	return MinMax{Min: math.Min(x.Min, y.Min), Max: math.Max(x.Max, y.Max)}
+}
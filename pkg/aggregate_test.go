@@ -0,0 +1,77 @@
+package octree
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/louis030195/protometry/api/volume"
+)
+
+// mass is the Data of the objects of the aggregate tests, integers so the sums are exact in any order
+func mass(object *Object) float64 {
+	return object.Data.(float64)
+}
+
+// aggregateObjects aggregates objects the slow way
+func aggregateObjects(a Aggregator, objects []Object) interface{} {
+	value := a.Empty()
+	for i := range objects {
+		value = a.Merge(value, a.Object(&objects[i]))
+	}
+	return value
+}
+
+func TestOctree_Aggregate(t *testing.T) {
+	count := CountAggregator{}
+	sum := &SumAggregator{Value: mass}
+	minMax := &MinMaxAggregator{Value: mass}
+	m := NewMetrics()
+	o := NewOctree(volume.NewBoxOfSize(0, 0, 0, 100),
+		WithAggregator(count), WithAggregator(sum), WithAggregator(minMax), WithMetrics(m))
+	equals(t, 0, o.Aggregate(count))
+	equals(t, minMax.Empty(), o.Aggregate(minMax))
+	// Not maintained by the tree
+	equals(t, nil, o.Aggregate(&SumAggregator{Value: mass}))
+	equals(t, nil, o.AggregateInBox(&SumAggregator{Value: mass}, o.root.region))
+
+	r := rand.New(rand.NewSource(1))
+	var objects []*Object
+	for i := 0; i < 500; i++ {
+		obj := NewObjectCube(float64(r.Intn(100)), r.Float64()*98-49, r.Float64()*98-49, r.Float64()*98-49, 1)
+		equals(t, true, o.Insert(*obj))
+		objects = append(objects, obj)
+	}
+	check := func() {
+		all := o.GetAllObjects()
+		for _, a := range []Aggregator{count, sum, minMax} {
+			equals(t, aggregateObjects(a, all), o.Aggregate(a))
+			for i := 0; i < 20; i++ {
+				bounds := *volume.NewBoxOfSize(r.Float64()*100-50, r.Float64()*100-50, r.Float64()*100-50, r.Float64()*60)
+				equals(t, aggregateObjects(a, o.GetColliding(bounds)), o.AggregateInBox(a, bounds))
+			}
+		}
+	}
+	check()
+
+	for _, obj := range objects[:200] {
+		equals(t, true, o.Remove(*obj))
+	}
+	check()
+	for _, obj := range objects[200:300] {
+		equals(t, true, o.Move(obj, r.Float64()*98-49, r.Float64()*98-49, r.Float64()*98-49))
+	}
+	check()
+	o.RangeMutate(func(obj *Object) bool {
+		obj.Data = obj.Data.(float64) + 1
+		return true
+	})
+	check()
+	o.Rebuild()
+	check()
+	equals(t, 300, o.Aggregate(count))
+
+	// The whole tree fits in the box, its aggregate is used as is
+	before := m.counters[countVisits]
+	equals(t, 300, o.AggregateInBox(count, *volume.NewBoxOfSize(0, 0, 0, 200)))
+	equals(t, before+1, m.counters[countVisits])
+}
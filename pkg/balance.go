@@ -0,0 +1,100 @@
+package octree
+
+import "github.com/louis030195/protometry/api/volume"
+
+// WithBalance21 keeps the tree 2:1 balanced, see Balance21, from its creation
+func WithBalance21() Option {
+	return func(o *Octree) {
+		o.balance21 = true
+	}
+}
+
+// Balance21 refines the leaves until no two adjacent leaves, sharing a face, an edge or a corner,
+// differ by more than one level, as required by the stencils of adaptive mesh refinement.
+// The tree then stays balanced: inserts refine the neighbours of the new leaves
+// and the merges which would unbalance it are skipped, as with WithBalance21.
+// A skipped merge is only retried on the next removal in the subtree or by Compact
+func (o *Octree) Balance21() {
+	o.version++
+	o.balance21 = true
+	balance(o.root.leaves())
+}
+
+// balance refines the leaves coarser than the neighbours of queue by more than one level, rippling to their neighbours
+func balance(queue []*Node) {
+	for len(queue) > 0 {
+		leaf := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		if leaf.children != nil {
+			continue
+		}
+		for _, d := range Directions {
+			for _, neighbor := range leaf.tree.Neighbors(leaf, d) {
+				if neighbor.depth < leaf.depth-1 {
+					neighbor.refine()
+					// The children may still be too coarse for leaf
+					queue = append(queue, leaf)
+					for i := range neighbor.children {
+						queue = append(queue, &neighbor.children[i])
+					}
+				}
+			}
+		}
+	}
+}
+
+// balanceAround balances the tree around the leaves which may have been created by inserting an object of bounds,
+// they are the children of the nodes fitting bounds
+func (o *Octree) balanceAround(bounds volume.Box) {
+	if !o.balance21 {
+		return
+	}
+	var queue []*Node
+	for n := o.root; n != nil && n.children != nil; {
+		var next *Node
+		for i := range n.children {
+			c := &n.children[i]
+			if c.children == nil {
+				queue = append(queue, c)
+			} else if next == nil && bounds.Fit(c.region) {
+				next = c
+			}
+		}
+		n = next
+	}
+	balance(queue)
+}
+
+// refine splits the leaf n, moving its objects to the children fitting them
+func (n *Node) refine() {
+	n.split()
+	objects := n.objects
+	n.objects = nil
+	for _, obj := range objects {
+		n.pushDown(obj)
+	}
+}
+
+// balanced21 returns whether a leaf at depth could replace n without unbalancing the tree
+func (n *Node) balanced21(depth int) bool {
+	for _, d := range Directions {
+		for _, neighbor := range n.tree.Neighbors(n, d) {
+			if neighbor.depth > depth+1 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// leaves returns the leaves of the subtree of n, in the DFS order
+func (n *Node) leaves() []*Node {
+	var leaves []*Node
+	n.walk(0, func(c *Node, _ int) bool {
+		if c.children == nil {
+			leaves = append(leaves, c)
+		}
+		return true
+	})
+	return leaves
+}
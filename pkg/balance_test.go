@@ -0,0 +1,79 @@
+package octree
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/louis030195/protometry/api/vector3"
+	"github.com/louis030195/protometry/api/volume"
+)
+
+// checkBalanced21 fails the test if two adjacent leaves differ by more than one level
+func checkBalanced21(t *testing.T, o *Octree) {
+	for _, leaf := range o.Leaves() {
+		for _, d := range Directions {
+			for _, neighbor := range o.Neighbors(leaf, d) {
+				diff := leaf.Depth() - neighbor.Depth()
+				equals(t, true, diff <= 1 && diff >= -1)
+			}
+		}
+	}
+}
+
+func TestOctree_Balance21(t *testing.T) {
+	o := refinedTree(t)
+	objects := o.GetAllObjects()
+	leaves := len(o.Leaves())
+	equals(t, true, o.Validate() == nil)
+	o.Balance21()
+	equals(t, true, len(o.Leaves()) > leaves)
+	checkBalanced21(t, o)
+	equals(t, nil, o.Validate())
+	equals(t, len(objects), o.getNumberOfObjects())
+
+	// The balance is kept while removing
+	for _, obj := range objects[:40] {
+		equals(t, true, o.Remove(obj))
+		equals(t, nil, o.Validate())
+	}
+	checkBalanced21(t, o)
+	for _, obj := range objects[40:] {
+		equals(t, true, o.Remove(obj))
+	}
+	equals(t, nil, o.Validate())
+	o.Compact()
+	equals(t, 1, len(o.GetNodes()))
+
+	// A leaf refined twice breaks it
+	o.Insert(*NewObjectCube(0, 1, 1, 1, 1))
+	o.root.refine()
+	o.root.children[0].refine()
+	o.root.children[0].children[7].refine()
+	validationError(t, o, "breaks the 2:1 balance")
+}
+
+func TestOctree_WithBalance21(t *testing.T) {
+	o := NewOctree(volume.NewBoxOfSize(0, 0, 0, 16), WithSplitThreshold(2), WithBalance21())
+	r := rand.New(rand.NewSource(2))
+	var objects []*Object
+	for i := 0; i < 100; i++ {
+		p := vector3.NewVector3(r.ExpFloat64()*2-7.9, r.ExpFloat64()*2-7.9, r.ExpFloat64()*2-7.9)
+		obj := NewObjectCube(i, p.X, p.Y, p.Z, 0.01)
+		equals(t, true, o.Insert(*obj))
+		objects = append(objects, obj)
+		equals(t, nil, o.Validate())
+	}
+	equals(t, true, o.getHeight() > 4)
+	for _, obj := range objects[:50] {
+		equals(t, true, o.Move(obj, r.Float64()*14-7, r.Float64()*14-7, r.Float64()*14-7))
+	}
+	equals(t, nil, o.Validate())
+	o.Rebuild()
+	equals(t, nil, o.Validate())
+	for _, obj := range objects[50:] {
+		equals(t, true, o.Remove(*obj))
+	}
+	o.Compact()
+	equals(t, nil, o.Validate())
+	checkBalanced21(t, o)
+}
@@ -0,0 +1,103 @@
+package octree
+
+import (
+	"math"
+
+	"github.com/louis030195/protometry/api/vector3"
+)
+
+// PointMass is a mass concentrated at a point, the center of mass of a set of objects
+type PointMass struct {
+	Mass   float64
+	Center vector3.Vector3
+}
+
+// MassAggregator aggregates the total mass of the objects and their center of mass, as a PointMass.
+// The mass of an object is at the center of its bounds. Use it as a pointer, &MassAggregator{...}
+type MassAggregator struct {
+	Mass func(*Object) float64
+}
+
+// Empty implements Aggregator
+func (m *MassAggregator) Empty() interface{} { return PointMass{} }
+
+// Object implements Aggregator
+func (m *MassAggregator) Object(object *Object) interface{} {
+	return PointMass{Mass: m.Mass(object), Center: object.Bounds.GetCenter()}
+}
+
+// Merge implements Aggregator
+func (m *MassAggregator) Merge(a, b interface{}) interface{} {
+	x, y := a.(PointMass), b.(PointMass)
+	mass := x.Mass + y.Mass
+	if mass == 0 {
+		return PointMass{}
+	}
+	return PointMass{Mass: mass, Center: x.Center.Times(x.Mass / mass).Plus(y.Center.Times(y.Mass / mass))}
+}
+
+// WithMass maintains the total mass and the center of mass of every node, mass returning the mass of an object,
+// see ApproximateField
+func WithMass(mass func(*Object) float64) Option {
+	return func(o *Octree) {
+		o.mass = &MassAggregator{Mass: mass}
+		WithAggregator(o.mass)(o)
+	}
+}
+
+// Kernel returns the field at point created by source, e.g. its gravitational acceleration.
+// It must handle a source at point, the object whose field is computed being usually in the tree too
+type Kernel func(point vector3.Vector3, source PointMass) vector3.Vector3
+
+// Gravity returns the kernel of the gravitational acceleration g * m / r², softened by softening
+// so that close sources don't create huge accelerations. A source at point has no effect
+func Gravity(g, softening float64) Kernel {
+	return func(point vector3.Vector3, source PointMass) vector3.Vector3 {
+		d := source.Center.Minus(point)
+		sqDistance := d.Norm()
+		if sqDistance == 0 {
+			return vector3.Vector3{}
+		}
+		r := math.Sqrt(sqDistance + softening*softening)
+		return d.Times(g * source.Mass / (r * r * r))
+	}
+}
+
+// ApproximateField returns the sum of kernel at point for all the objects of a tree created WithMass,
+// in O(log n) instead of O(n) as in Barnes–Hut simulations: the nodes seen from point under an angle
+// smaller than theta, their size divided by the distance to their center of mass, are a single source.
+// theta = 0 computes the exact sum, 0.5 is usually accurate enough.
+// It returns the zero vector if the tree was created without WithMass
+func (o *Octree) ApproximateField(point vector3.Vector3, theta float64, kernel Kernel) vector3.Vector3 {
+	var field vector3.Vector3
+	if o.mass == nil {
+		return field
+	}
+	i := o.aggregatorIndex(o.mass)
+	o.root.approximateField(point, theta*theta, kernel, i, &field)
+	return field
+}
+
+// approximateField adds to field the one of the subtree, i being the index of the masses in the aggregates
+func (n *Node) approximateField(point vector3.Vector3, sqTheta float64, kernel Kernel, i int, field *vector3.Vector3) {
+	n.metrics().add(countVisits)
+	source := n.aggregates[i].(PointMass)
+	if source.Mass == 0 {
+		return
+	}
+	size := n.region.GetSize()
+	s := math.Max(size.X, math.Max(size.Y, size.Z))
+	// s / d < theta, squared
+	if sqDistance := source.Center.Minus(point).Norm(); s*s < sqTheta*sqDistance {
+		*field = field.Plus(kernel(point, source))
+		return
+	}
+	for j := range n.objects {
+		*field = field.Plus(kernel(point, n.tree.mass.Object(&n.objects[j]).(PointMass)))
+	}
+	if n.children != nil {
+		for j := range n.children {
+			n.children[j].approximateField(point, sqTheta, kernel, i, field)
+		}
+	}
+}
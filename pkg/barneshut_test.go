@@ -0,0 +1,47 @@
+package octree
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/louis030195/protometry/api/vector3"
+	"github.com/louis030195/protometry/api/volume"
+)
+
+func TestMassAggregator(t *testing.T) {
+	a := &MassAggregator{Mass: mass}
+	objects := []Object{*NewObjectCube(1., 0, 0, 0, 1), *NewObjectCube(3., 4, 0, 0, 1)}
+	equals(t, PointMass{Mass: 4, Center: *vector3.NewVector3(3, 0, 0)}, aggregateObjects(a, objects))
+	equals(t, PointMass{}, aggregateObjects(a, nil))
+}
+
+func TestOctree_ApproximateField(t *testing.T) {
+	m := NewMetrics()
+	o := NewOctree(volume.NewBoxOfSize(0, 0, 0, 250), WithMass(mass), WithMetrics(m))
+	equals(t, vector3.Vector3{}, NewOctree(&o.root.region).ApproximateField(vector3.Vector3{}, 0.5, Gravity(1, 1)))
+
+	r := rand.New(rand.NewSource(1))
+	var objects []Object
+	for i := 0; i < 2000; i++ {
+		p := vector3.RandomSpherePoint(vector3.Vector3{}, 100)
+		obj := *NewObjectCube(float64(r.Intn(10)+1), p.X, p.Y, p.Z, 0.1)
+		equals(t, true, o.Insert(obj))
+		objects = append(objects, obj)
+	}
+	gravity := Gravity(1, 1)
+	a := &MassAggregator{Mass: mass}
+	for _, obj := range objects[:20] {
+		point := obj.Bounds.GetCenter()
+		var exact vector3.Vector3
+		for i := range objects {
+			exact = exact.Plus(gravity(point, a.Object(&objects[i]).(PointMass)))
+		}
+		equals(t, true, o.ApproximateField(point, 0, gravity).Minus(exact).Norm2() < exact.Norm2()*1e-9)
+
+		before := m.counters[countVisits]
+		approximate := o.ApproximateField(point, 0.5, gravity)
+		equals(t, true, approximate.Minus(exact).Norm2() < exact.Norm2()*0.05)
+		// Far from visiting the whole tree
+		equals(t, true, m.counters[countVisits]-before < uint64(o.getNumberOfNodes()/2))
+	}
+}
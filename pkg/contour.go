@@ -0,0 +1,238 @@
+package octree
+
+import (
+	"github.com/louis030195/protometry/api/vector3"
+	"github.com/louis030195/protometry/api/volume"
+)
+
+// Density is a scalar field, negative inside the volume and positive outside, e.g. SDF.SampleDistance
+// or Octree.Occupancy
+type Density func(point vector3.Vector3) float64
+
+// Occupancy is a Density of the objects of the tree: -1 on any object, 1 elsewhere
+func (o *Octree) Occupancy(point vector3.Vector3) float64 {
+	found := false
+	o.root.visitColliding(volume.Box{Min: &point, Max: &point}, AllLayers, func(*Object) bool {
+		found = true
+		return false
+	})
+	if found {
+		return -1
+	}
+	return 1
+}
+
+// ExtractSurface returns the surface where density crosses 0, sampled at the corners of the leaves of the tree,
+// the triangles facing outside, with a normal per vertex. It is dual contouring: each leaf crossed by the surface
+// holds a vertex, at the mean of the crossings of its edges, and the leaves around each edge crossed by the surface
+// are connected by a quad, or a triangle if a leaf is larger than the others. The edges are the ones of the smallest
+// leaves so the surface has no crack between leaves of different sizes. Like any dual contouring, the surface
+// is closed but not always manifold, a leaf crossed several times by the surface holding a single vertex
+func (o *Octree) ExtractSurface(density Density) *volume.Mesh {
+	c := &contour{density: density, samples: map[[3]float64]float64{}, vertices: map[*Node]int{}}
+	c.cell(o.root)
+
+	mesh := &volume.Mesh{}
+	size := o.root.region.GetSize()
+	eps := size.X * 1e-4
+	for i := range c.sums {
+		p := c.sums[i].Times(1 / float64(c.counts[i]))
+		mesh.Vertices = append(mesh.Vertices, vector3.NewVector3(p.X, p.Y, p.Z))
+		// The gradient of the density points outside
+		gradient := *vector3.NewVector3(
+			density(p.Plus(*vector3.NewVector3(eps, 0, 0)))-density(p.Minus(*vector3.NewVector3(eps, 0, 0))),
+			density(p.Plus(*vector3.NewVector3(0, eps, 0)))-density(p.Minus(*vector3.NewVector3(0, eps, 0))),
+			density(p.Plus(*vector3.NewVector3(0, 0, eps)))-density(p.Minus(*vector3.NewVector3(0, 0, eps))),
+		)
+		n := normalize(gradient)
+		mesh.Normals = append(mesh.Normals, &n)
+	}
+	mesh.Tris = c.tris
+	return mesh
+}
+
+// contour holds the state of ExtractSurface
+type contour struct {
+	density Density
+	// samples caches the density at the corners of the leaves
+	samples map[[3]float64]float64
+	// vertices are the indices of the vertices of the leaves crossed by the surface
+	vertices map[*Node]int
+	// sums and counts of the crossings of the edges of each vertex
+	sums   []vector3.Vector3
+	counts []int
+	tris   []int32
+}
+
+// axisBits are the bits of the child index of each axis
+var axisBits = [3]int{4, 2, 1}
+
+// edgeAxes returns the two other axes of axis, in cyclic order, the 4 cells around an edge along axis
+// being ordered counterclockwise around it: low low, high low, high high, low high along these axes
+func edgeAxes(axis int) (int, int) {
+	return (axis + 1) % 3, (axis + 2) % 3
+}
+
+// edgeSides are the sides of the 4 cells around an edge along the two other axes, 0 for the low side
+var edgeSides = [4][2]int{{0, 0}, {1, 0}, {1, 1}, {0, 1}}
+
+// child returns the child of n at index, or n if it is a leaf
+func child(n *Node, index int) *Node {
+	if n.children == nil {
+		return n
+	}
+	return &n.children[index]
+}
+
+// cell contours the subtree of n
+func (c *contour) cell(n *Node) {
+	if n.children == nil {
+		return
+	}
+	for i := range n.children {
+		c.cell(&n.children[i])
+	}
+	for axis, bit := range axisBits {
+		// The 4 faces between the children along axis
+		for i := range n.children {
+			if i&bit == 0 {
+				c.face(&n.children[i], &n.children[i|bit], axis)
+			}
+		}
+		// The 2 edges along axis at the center of n
+		b, d := edgeAxes(axis)
+		for half := 0; half < 2; half++ {
+			var nodes [4]*Node
+			for k, sides := range edgeSides {
+				nodes[k] = &n.children[half*bit|sides[0]*axisBits[b]|sides[1]*axisBits[d]]
+			}
+			c.edge(nodes, axis)
+		}
+	}
+}
+
+// face contours the face between low and high, along axis
+func (c *contour) face(low, high *Node, axis int) {
+	if low.children == nil && high.children == nil {
+		return
+	}
+	bit := axisBits[axis]
+	b, d := edgeAxes(axis)
+	// The 4 sub faces
+	for i := 0; i < 8; i++ {
+		if i&bit == 0 {
+			c.face(child(low, i|bit), child(high, i), axis)
+		}
+	}
+	// The 4 edges in the face, 2 along each of the other axes
+	for _, e := range [2]int{b, d} {
+		eb, ed := edgeAxes(e)
+		// f is the axis of the face crossing the edge, other than axis
+		f := b + d - e
+		for half := 0; half < 2; half++ {
+			var nodes [4]*Node
+			for k, sides := range edgeSides {
+				side := [3]int{}
+				side[eb], side[ed] = sides[0], sides[1]
+				parent := low
+				if side[axis] == 1 {
+					parent = high
+				}
+				// The cells touch the face with their opposite side along axis, the edge with theirs along f
+				nodes[k] = child(parent, half*axisBits[e]|(1-side[axis])*bit|side[f]*axisBits[f])
+			}
+			c.edge(nodes, e)
+		}
+	}
+}
+
+// edge contours the edge along axis surrounded by nodes, ordered as edgeSides
+func (c *contour) edge(nodes [4]*Node, axis int) {
+	leaves := true
+	for _, n := range nodes {
+		leaves = leaves && n.children == nil
+	}
+	if leaves {
+		c.minimalEdge(nodes, axis)
+		return
+	}
+	b, d := edgeAxes(axis)
+	for half := 0; half < 2; half++ {
+		var children [4]*Node
+		for k, sides := range edgeSides {
+			// The children touching the edge, on the other side than their parent
+			children[k] = child(nodes[k], half*axisBits[axis]|(1-sides[0])*axisBits[b]|(1-sides[1])*axisBits[d])
+		}
+		c.edge(children, axis)
+	}
+}
+
+// minimalEdge connects the vertices of the leaves around an edge crossed by the surface,
+// the edge being the one of the smallest leaf
+func (c *contour) minimalEdge(nodes [4]*Node, axis int) {
+	smallest := 0
+	for k := range nodes {
+		if nodes[k].depth > nodes[smallest].depth {
+			smallest = k
+		}
+	}
+	b, d := edgeAxes(axis)
+	sides := edgeSides[smallest]
+	corner := (1-sides[0])*axisBits[b] | (1-sides[1])*axisBits[d]
+	r := nodes[smallest].region
+	p0 := cellPoint(r, float64(corner>>2&1), float64(corner>>1&1), float64(corner&1))
+	corner |= axisBits[axis]
+	p1 := cellPoint(r, float64(corner>>2&1), float64(corner>>1&1), float64(corner&1))
+	d0, d1 := c.sample(p0), c.sample(p1)
+	if (d0 < 0) == (d1 < 0) {
+		return
+	}
+	crossing := p0.Plus(p1.Minus(p0).Times(d0 / (d0 - d1)))
+	var polygon []int32
+	for _, n := range nodes {
+		v := c.vertex(n)
+		c.sums[v] = c.sums[v].Plus(crossing)
+		c.counts[v]++
+		if len(polygon) == 0 || polygon[len(polygon)-1] != int32(v) {
+			polygon = append(polygon, int32(v))
+		}
+	}
+	if len(polygon) > 1 && polygon[0] == polygon[len(polygon)-1] {
+		polygon = polygon[:len(polygon)-1]
+	}
+	if len(polygon) < 3 {
+		return
+	}
+	// Counterclockwise around the edge faces +axis, flipped when the inside is on the +axis end
+	if d1 < 0 {
+		for i, j := 0, len(polygon)-1; i < j; i, j = i+1, j-1 {
+			polygon[i], polygon[j] = polygon[j], polygon[i]
+		}
+	}
+	for i := 1; i+1 < len(polygon); i++ {
+		c.tris = append(c.tris, polygon[0], polygon[i], polygon[i+1])
+	}
+}
+
+// sample returns the density at p, cached as the corners are shared by the leaves
+func (c *contour) sample(p vector3.Vector3) float64 {
+	key := [3]float64{p.X, p.Y, p.Z}
+	if d, ok := c.samples[key]; ok {
+		return d
+	}
+	d := c.density(p)
+	c.samples[key] = d
+	return d
+}
+
+// vertex returns the index of the vertex of the leaf n
+func (c *contour) vertex(n *Node) int {
+	if v, ok := c.vertices[n]; ok {
+		return v
+	}
+	v := len(c.sums)
+	c.vertices[n] = v
+	c.sums = append(c.sums, vector3.Vector3{})
+	c.counts = append(c.counts, 0)
+	return v
+}
@@ -0,0 +1,70 @@
+package octree
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/louis030195/protometry/api/vector3"
+	"github.com/louis030195/protometry/api/volume"
+)
+
+// checkClosed fails the test unless every edge of mesh is shared by as many triangles in both directions
+func checkClosed(t *testing.T, mesh *volume.Mesh) {
+	edges := map[[2]int32]int{}
+	for i := 0; i < len(mesh.Tris); i += 3 {
+		for j := 0; j < 3; j++ {
+			edges[[2]int32{mesh.Tris[i+j], mesh.Tris[i+(j+1)%3]}]++
+		}
+	}
+	for e, n := range edges {
+		equals(t, n, edges[[2]int32{e[1], e[0]}])
+	}
+}
+
+func sphereDensity(p vector3.Vector3) float64 {
+	return p.Norm2() - 1
+}
+
+func TestOctree_ExtractSurface(t *testing.T) {
+	for _, opts := range [][]Option{{WithSplitThreshold(1)}, {WithSplitThreshold(1), WithBalance21()}} {
+		o := NewOctree(volume.NewBoxOfSize(0.1, 0.2, 0.05, 4), opts...)
+		r := rand.New(rand.NewSource(1))
+		for i := 0; i < 300; i++ {
+			// Finer on one side of the sphere
+			p := *vector3.NewVector3(r.Float64()*2-1, r.Float64()*2-1, r.Float64()*2-1)
+			if r.Intn(2) == 0 {
+				p.X = math.Abs(p.X)
+			}
+			equals(t, true, o.Insert(*NewObjectCube(i, p.X, p.Y, p.Z, 0.001)))
+		}
+		mesh := o.ExtractSurface(sphereDensity)
+		equals(t, true, len(mesh.Tris) > 100)
+		equals(t, len(mesh.Vertices), len(mesh.Normals))
+		checkClosed(t, mesh)
+		for i, v := range mesh.Vertices {
+			equals(t, true, math.Abs(v.Norm2()-1) < 0.1)
+			equals(t, true, mesh.Normals[i].Dot(normalize(*v)) > 0.99)
+		}
+		// Facing outside, the signed volume is the one of the sphere
+		volume := 0.
+		for i := 0; i < len(mesh.Tris); i += 3 {
+			a, b, c := *mesh.Vertices[mesh.Tris[i]], *mesh.Vertices[mesh.Tris[i+1]], *mesh.Vertices[mesh.Tris[i+2]]
+			volume += a.Dot(cross(b, c)) / 6
+		}
+		// The vertices are inside the sphere, on a coarse approximation of it
+		equals(t, true, volume > 0.8*4*math.Pi/3 && volume < 4*math.Pi/3)
+	}
+}
+
+func TestOctree_ExtractSurfaceOccupancy(t *testing.T) {
+	// A voxel at the center of a tree refined around it
+	o := NewOctree(volume.NewBoxOfSize(0, 0, 0, 8), WithSplitThreshold(1))
+	equals(t, true, o.Insert(*NewObjectCube(0, 0.5, 0.5, 0.5, 1)))
+	equals(t, true, o.Insert(*NewObjectCube(1, 3.5, 3.5, 3.5, 1)))
+	equals(t, -1., o.Occupancy(*vector3.NewVector3(0.5, 0.5, 0.5)))
+	equals(t, 1., o.Occupancy(*vector3.NewVector3(-0.5, 0.5, 0.5)))
+	mesh := o.ExtractSurface(o.Occupancy)
+	equals(t, true, len(mesh.Tris) > 0)
+	checkClosed(t, mesh)
+}
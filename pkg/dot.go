@@ -0,0 +1,96 @@
+package octree
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"github.com/louis030195/protometry/api/volume"
+)
+
+// DOTOption configures WriteDOT
+type DOTOption func(*dotConfig)
+
+type dotConfig struct {
+	collapseEmpty bool
+	maxDepth      int
+}
+
+// DOTCollapseEmpty draws the children of a node without any object in their subtree as a single vertex
+func DOTCollapseEmpty() DOTOption {
+	return func(c *dotConfig) {
+		c.collapseEmpty = true
+	}
+}
+
+// DOTMaxDepth stops the graph at the given depth, the root being at depth 0.
+// The nodes at this depth are labelled with the number of nodes left out below them
+func DOTMaxDepth(depth int) DOTOption {
+	return func(c *dotConfig) {
+		c.maxDepth = depth
+	}
+}
+
+type dotVertex struct {
+	label string
+	// empty is the number of nodes of collapsed empty subtrees, 0 for a regular vertex
+	empty int
+}
+
+// WriteDOT writes the node hierarchy as a Graphviz graph, each node labelled with its depth,
+// region and number of objects, e.g. octree.WriteDOT(f, octree.DOTCollapseEmpty()) then dot -Tsvg
+func (o *Octree) WriteDOT(w io.Writer, opts ...DOTOption) error {
+	config := dotConfig{maxDepth: -1}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	var vertices []dotVertex
+	var edges [][2]int
+	// Vertex of the last node seen at each depth, and of the collapsed empty children of this node
+	var parents, collapsed []int
+	o.root.walk(0, func(n *Node, depth int) bool {
+		parents = append(parents[:depth], len(vertices))
+		collapsed = append(collapsed[:depth], -1)
+
+		if depth > 0 && config.collapseEmpty && n.getNumberOfObjects() == 0 {
+			group := &collapsed[depth-1]
+			if *group < 0 {
+				*group = len(vertices)
+				vertices = append(vertices, dotVertex{})
+				edges = append(edges, [2]int{parents[depth-1], *group})
+			}
+			vertices[*group].empty += n.getNumberOfNodes()
+			return false
+		}
+
+		label := fmt.Sprintf("depth %v\\n%v\\n%v objects", depth, formatRegion(n.region), len(n.objects))
+		if depth == config.maxDepth && n.children != nil {
+			label += fmt.Sprintf("\\n+%v nodes", n.getNumberOfNodes()-1)
+		}
+		if depth > 0 {
+			edges = append(edges, [2]int{parents[depth-1], len(vertices)})
+		}
+		vertices = append(vertices, dotVertex{label: label})
+		return depth != config.maxDepth
+	})
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "digraph octree {\n\tnode [shape=box, fontname=monospace];\n")
+	for i, v := range vertices {
+		if v.empty > 0 {
+			fmt.Fprintf(bw, "\tn%v [label=\"%v empty nodes\", style=dashed];\n", i, v.empty)
+			continue
+		}
+		fmt.Fprintf(bw, "\tn%v [label=\"%v\"];\n", i, v.label)
+	}
+	for _, e := range edges {
+		fmt.Fprintf(bw, "\tn%v -> n%v;\n", e[0], e[1])
+	}
+	fmt.Fprintf(bw, "}\n")
+	return bw.Flush()
+}
+
+func formatRegion(b volume.Box) string {
+	return fmt.Sprintf("[%v %v %v, %v %v %v]", b.Min.X, b.Min.Y, b.Min.Z, b.Max.X, b.Max.Y, b.Max.Z)
+}
@@ -0,0 +1,42 @@
+package octree
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/louis030195/protometry/api/volume"
+)
+
+func TestOctree_WriteDOT(t *testing.T) {
+	o := NewOctree(volume.NewBoxOfSize(0, 0, 0, 8))
+	for i := 0; i < CAPACITY+1; i++ {
+		equals(t, true, o.Insert(*NewObjectCube(i, 2, 2, 2, 1)))
+	}
+	// Root, 8 children and the 8 children of the +x+y+z one
+	equals(t, 17, len(o.GetNodes()))
+
+	var b bytes.Buffer
+	equals(t, nil, o.WriteDOT(&b))
+	dot := b.String()
+	equals(t, true, strings.HasPrefix(dot, "digraph octree {\n"))
+	equals(t, 17, strings.Count(dot, "[label="))
+	equals(t, 16, strings.Count(dot, " -> "))
+	equals(t, true, strings.Contains(dot, `n0 [label="depth 0\n[-4 -4 -4, 4 4 4]\n0 objects"];`))
+	equals(t, true, strings.Contains(dot, `depth 1\n[0 0 0, 4 4 4]\n6 objects`))
+
+	b.Reset()
+	equals(t, nil, o.WriteDOT(&b, DOTCollapseEmpty()))
+	dot = b.String()
+	// Root, its 7 empty children, the full child and its 8 empty children
+	equals(t, 4, strings.Count(dot, "[label="))
+	equals(t, true, strings.Contains(dot, `n1 [label="7 empty nodes", style=dashed];`))
+	equals(t, true, strings.Contains(dot, `n3 [label="8 empty nodes", style=dashed];`))
+	equals(t, true, strings.Contains(dot, "n2 -> n3;"))
+
+	b.Reset()
+	equals(t, nil, o.WriteDOT(&b, DOTMaxDepth(1), DOTCollapseEmpty()))
+	dot = b.String()
+	equals(t, 3, strings.Count(dot, "[label="))
+	equals(t, true, strings.Contains(dot, `6 objects\n+8 nodes"];`))
+}
@@ -0,0 +1,257 @@
+package octree
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/louis030195/protometry/api/volume"
+)
+
+// ColorMode selects how the node cubes are colored by ExportOBJ and ExportGLTF
+type ColorMode int
+
+const (
+	// ColorByDepth goes from blue at the root to red at the deepest leaves
+	ColorByDepth ColorMode = iota
+	// ColorByOccupancy goes from blue for empty nodes to red for nodes holding the split threshold objects or more
+	ColorByOccupancy
+)
+
+// ExportOptions configures ExportOBJ and ExportGLTF
+type ExportOptions struct {
+	Color ColorMode
+	// Objects also exports the bounds of the objects, in white
+	Objects bool
+}
+
+// wireCube is a box drawn as its 12 edges
+type wireCube struct {
+	box   volume.Box
+	color [3]float32
+}
+
+var objectColor = [3]float32{1, 1, 1}
+
+// cubeEdges are the pairs of corners of a cube, a corner index having its bits 0, 1 and 2
+// set when it is at the max of the box on x, y and z respectively
+var cubeEdges = func() [][2]int {
+	var edges [][2]int
+	for i := 0; i < 8; i++ {
+		for bit := 1; bit < 8; bit <<= 1 {
+			if i&bit == 0 {
+				edges = append(edges, [2]int{i, i | bit})
+			}
+		}
+	}
+	return edges
+}()
+
+func cubeCorner(b volume.Box, i int) [3]float64 {
+	c := [3]float64{b.Min.X, b.Min.Y, b.Min.Z}
+	if i&1 != 0 {
+		c[0] = b.Max.X
+	}
+	if i&2 != 0 {
+		c[1] = b.Max.Y
+	}
+	if i&4 != 0 {
+		c[2] = b.Max.Z
+	}
+	return c
+}
+
+// heatColor maps t in [0, 1] from blue to red through green
+func heatColor(t float64) [3]float32 {
+	t = math.Max(0, math.Min(1, t))
+	// Hue from 240° to 0°
+	h := (1 - t) * 4
+	x := float32(1 - math.Abs(math.Mod(h, 2)-1))
+	switch {
+	case h < 1:
+		return [3]float32{1, x, 0}
+	case h < 2:
+		return [3]float32{x, 1, 0}
+	case h < 3:
+		return [3]float32{0, 1, x}
+	}
+	return [3]float32{0, x, 1}
+}
+
+// wireframe returns a cube per node region, and per object bounds if asked
+func (o *Octree) wireframe(opts ExportOptions) (nodes, objects []wireCube) {
+	maxDepth := o.getHeight() - 1
+	o.root.walk(0, func(n *Node, depth int) bool {
+		var t float64
+		switch opts.Color {
+		case ColorByOccupancy:
+			t = float64(len(n.objects)) / float64(o.getSplitThreshold())
+		default:
+			if maxDepth > 0 {
+				t = float64(depth) / float64(maxDepth)
+			}
+		}
+		nodes = append(nodes, wireCube{box: n.region, color: heatColor(t)})
+		if opts.Objects {
+			for _, obj := range n.objects {
+				objects = append(objects, wireCube{box: obj.Bounds, color: objectColor})
+			}
+		}
+		return true
+	})
+	return nodes, objects
+}
+
+// ExportOBJ writes the node regions as wireframe cubes in the Wavefront OBJ format, e.g. to inspect the splits in Blender.
+// The colors are written after the vertex positions, an extension most viewers understand.
+func (o *Octree) ExportOBJ(w io.Writer, opts ExportOptions) error {
+	bw := bufio.NewWriter(w)
+	nodes, objects := o.wireframe(opts)
+	fmt.Fprintf(bw, "# octree: %v nodes, %v objects\n", len(nodes), len(objects))
+	vertices := 0
+	writeGroup := func(name string, cubes []wireCube) {
+		if len(cubes) == 0 {
+			return
+		}
+		fmt.Fprintf(bw, "o %v\n", name)
+		for _, c := range cubes {
+			for i := 0; i < 8; i++ {
+				p := cubeCorner(c.box, i)
+				fmt.Fprintf(bw, "v %v %v %v %v %v %v\n", p[0], p[1], p[2], c.color[0], c.color[1], c.color[2])
+			}
+			for _, e := range cubeEdges {
+				// OBJ indices start at 1
+				fmt.Fprintf(bw, "l %v %v\n", vertices+e[0]+1, vertices+e[1]+1)
+			}
+			vertices += 8
+		}
+	}
+	writeGroup("nodes", nodes)
+	writeGroup("objects", objects)
+	return bw.Flush()
+}
+
+// glTF 2.0 constants
+const (
+	gltfFloat        = 5126
+	gltfUnsignedInt  = 5125
+	gltfArrayBuffer  = 34962
+	gltfElementArray = 34963
+	gltfLines        = 1
+)
+
+type gltfAccessor struct {
+	BufferView    int       `json:"bufferView"`
+	ComponentType int       `json:"componentType"`
+	Count         int       `json:"count"`
+	Type          string    `json:"type"`
+	Min           []float32 `json:"min,omitempty"`
+	Max           []float32 `json:"max,omitempty"`
+}
+
+type gltfBufferView struct {
+	Buffer     int `json:"buffer"`
+	ByteOffset int `json:"byteOffset"`
+	ByteLength int `json:"byteLength"`
+	Target     int `json:"target"`
+}
+
+type gltfPrimitive struct {
+	Attributes map[string]int `json:"attributes"`
+	Indices    int            `json:"indices"`
+	Mode       int            `json:"mode"`
+}
+
+type gltfMesh struct {
+	Name       string          `json:"name"`
+	Primitives []gltfPrimitive `json:"primitives"`
+}
+
+type gltfNode struct {
+	Name string `json:"name"`
+	Mesh int    `json:"mesh"`
+}
+
+type gltfBuffer struct {
+	ByteLength int    `json:"byteLength"`
+	URI        string `json:"uri"`
+}
+
+type gltf struct {
+	Asset       map[string]string  `json:"asset"`
+	Scene       int                `json:"scene"`
+	Scenes      []map[string][]int `json:"scenes"`
+	Nodes       []gltfNode         `json:"nodes"`
+	Meshes      []gltfMesh         `json:"meshes"`
+	Accessors   []gltfAccessor     `json:"accessors"`
+	BufferViews []gltfBufferView   `json:"bufferViews"`
+	Buffers     []gltfBuffer       `json:"buffers"`
+}
+
+// ExportGLTF writes the node regions as wireframe cubes in a self-contained glTF 2.0 JSON file,
+// the binary data being embedded as a base64 data URI. Nodes and objects are separate meshes.
+func (o *Octree) ExportGLTF(w io.Writer, opts ExportOptions) error {
+	nodes, objects := o.wireframe(opts)
+	doc := gltf{
+		Asset:  map[string]string{"version": "2.0", "generator": "github.com/louis030195/octree"},
+		Scenes: []map[string][]int{{"nodes": {}}},
+	}
+	var data bytes.Buffer
+	// addView appends v to the buffer and returns its accessor
+	addView := func(v interface{}, target, componentType, count int, typ string) int {
+		offset := data.Len()
+		binary.Write(&data, binary.LittleEndian, v)
+		doc.BufferViews = append(doc.BufferViews, gltfBufferView{ByteOffset: offset, ByteLength: data.Len() - offset, Target: target})
+		doc.Accessors = append(doc.Accessors, gltfAccessor{BufferView: len(doc.BufferViews) - 1, ComponentType: componentType, Count: count, Type: typ})
+		return len(doc.Accessors) - 1
+	}
+	addMesh := func(name string, cubes []wireCube) {
+		if len(cubes) == 0 {
+			return
+		}
+		positions := make([]float32, 0, len(cubes)*8*3)
+		colors := make([]float32, 0, len(cubes)*8*3)
+		indices := make([]uint32, 0, len(cubes)*len(cubeEdges)*2)
+		min := []float32{math.MaxFloat32, math.MaxFloat32, math.MaxFloat32}
+		max := []float32{-math.MaxFloat32, -math.MaxFloat32, -math.MaxFloat32}
+		for i, c := range cubes {
+			for j := 0; j < 8; j++ {
+				p := cubeCorner(c.box, j)
+				for k := range p {
+					v := float32(p[k])
+					positions = append(positions, v)
+					min[k] = float32(math.Min(float64(min[k]), float64(v)))
+					max[k] = float32(math.Max(float64(max[k]), float64(v)))
+				}
+				colors = append(colors, c.color[:]...)
+			}
+			for _, e := range cubeEdges {
+				indices = append(indices, uint32(i*8+e[0]), uint32(i*8+e[1]))
+			}
+		}
+		position := addView(positions, gltfArrayBuffer, gltfFloat, len(positions)/3, "VEC3")
+		// The position accessor must have its bounds
+		doc.Accessors[position].Min, doc.Accessors[position].Max = min, max
+		color := addView(colors, gltfArrayBuffer, gltfFloat, len(colors)/3, "VEC3")
+		index := addView(indices, gltfElementArray, gltfUnsignedInt, len(indices), "SCALAR")
+		doc.Meshes = append(doc.Meshes, gltfMesh{Name: name, Primitives: []gltfPrimitive{{
+			Attributes: map[string]int{"POSITION": position, "COLOR_0": color},
+			Indices:    index,
+			Mode:       gltfLines,
+		}}})
+		doc.Nodes = append(doc.Nodes, gltfNode{Name: name, Mesh: len(doc.Meshes) - 1})
+		doc.Scenes[0]["nodes"] = append(doc.Scenes[0]["nodes"], len(doc.Nodes)-1)
+	}
+	addMesh("nodes", nodes)
+	addMesh("objects", objects)
+	doc.Buffers = []gltfBuffer{{
+		ByteLength: data.Len(),
+		URI:        "data:application/octet-stream;base64," + base64.StdEncoding.EncodeToString(data.Bytes()),
+	}}
+	return json.NewEncoder(w).Encode(doc)
+}
@@ -0,0 +1,64 @@
+package octree
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/louis030195/protometry/api/volume"
+)
+
+func exportTree(t *testing.T) *Octree {
+	o := NewOctree(volume.NewBoxOfSize(0, 0, 0, 8))
+	for i := 0; i < CAPACITY+1; i++ {
+		equals(t, true, o.Insert(*NewObjectCube(i, 2, 2, 2, 1)))
+	}
+	// The objects end up in a node at depth 1 which has its own children
+	equals(t, 17, len(o.GetNodes()))
+	return o
+}
+
+func TestOctree_ExportOBJ(t *testing.T) {
+	o := exportTree(t)
+	var b bytes.Buffer
+	equals(t, nil, o.ExportOBJ(&b, ExportOptions{}))
+	equals(t, 17*8, strings.Count(b.String(), "\nv "))
+	equals(t, 17*12, strings.Count(b.String(), "\nl "))
+	// The root is blue, the leaves red
+	equals(t, true, strings.Contains(b.String(), "\nv -4 -4 -4 0 0 1\n"))
+	equals(t, true, strings.Contains(b.String(), "\nv 0 0 0 1 0 0\n"))
+	equals(t, false, strings.Contains(b.String(), "o objects"))
+
+	b.Reset()
+	equals(t, nil, o.ExportOBJ(&b, ExportOptions{Color: ColorByOccupancy, Objects: true}))
+	equals(t, (17+CAPACITY+1)*8, strings.Count(b.String(), "\nv "))
+	// Last object, indices start at 1
+	equals(t, true, strings.HasSuffix(b.String(), "\nl 183 184\n"))
+	// The full leaf is red
+	equals(t, true, strings.Contains(b.String(), "\nv 4 4 4 1 0 0\n"))
+}
+
+func TestOctree_ExportGLTF(t *testing.T) {
+	o := exportTree(t)
+	var b bytes.Buffer
+	equals(t, nil, o.ExportGLTF(&b, ExportOptions{Objects: true}))
+	var doc gltf
+	equals(t, nil, json.Unmarshal(b.Bytes(), &doc))
+	equals(t, "2.0", doc.Asset["version"])
+	equals(t, []int{0, 1}, doc.Scenes[0]["nodes"])
+	equals(t, 2, len(doc.Meshes))
+	equals(t, 6, len(doc.Accessors))
+	equals(t, 17*8, doc.Accessors[0].Count)
+	equals(t, []float32{-4, -4, -4}, doc.Accessors[0].Min)
+	equals(t, 17*24, doc.Accessors[2].Count)
+	equals(t, (CAPACITY+1)*8, doc.Accessors[3].Count)
+
+	uri := doc.Buffers[0].URI
+	data, err := base64.StdEncoding.DecodeString(uri[strings.Index(uri, ",")+1:])
+	equals(t, nil, err)
+	equals(t, doc.Buffers[0].ByteLength, len(data))
+	last := doc.BufferViews[len(doc.BufferViews)-1]
+	equals(t, len(data), last.ByteOffset+last.ByteLength)
+}
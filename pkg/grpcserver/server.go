@@ -0,0 +1,260 @@
+// Package grpcserver exposes an Octree as a gRPC service, see api/octreepb/octree.proto
+package grpcserver
+
+import (
+	"context"
+	"sync"
+
+	"github.com/louis030195/octree/api/octreepb"
+	octree "github.com/louis030195/octree/pkg"
+	"github.com/louis030195/protometry/api/vector3"
+	"github.com/louis030195/protometry/api/volume"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// WatchBuffer is the number of events a watcher can lag behind before being disconnected
+var WatchBuffer = 64
+
+// Server implements octreepb.OctreeServer, it is safe for concurrent use
+type Server struct {
+	octreepb.UnimplementedOctreeServer
+
+	mu       sync.RWMutex
+	region   volume.Box
+	tree     *octree.Octree
+	objects  map[uint64]*octree.Object
+	watchers map[*watcher]struct{}
+}
+
+// watcher is a Watch stream subscribed to a region
+type watcher struct {
+	region volume.Box
+	events chan *octreepb.Event
+	// closed when the watcher lagged too much and has been dropped
+	dropped chan struct{}
+}
+
+// NewServer is a Server constructor, serving an empty Octree covering region,
+// created with the given options e.g. octree.WithMetrics
+func NewServer(region *volume.Box, opts ...octree.Option) *Server {
+	return &Server{
+		region:   *region,
+		tree:     octree.NewOctree(region, opts...),
+		objects:  map[uint64]*octree.Object{},
+		watchers: map[*watcher]struct{}{},
+	}
+}
+
+// Insert adds a new object, its id is assigned by the server
+func (s *Server) Insert(_ context.Context, req *octreepb.InsertRequest) (*octreepb.Object, error) {
+	bounds, err := boxFromProto(req.GetBounds())
+	if err != nil {
+		return nil, err
+	}
+	obj := octree.NewObject(req.GetData(), bounds)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.tree.Insert(*obj) {
+		return nil, status.Errorf(codes.OutOfRange, "bounds %v don't fit in the tree", req.GetBounds())
+	}
+	s.objects[obj.ID()] = obj
+	res := objectToProto(obj)
+	s.notify(octreepb.Event_INSERT, res, obj.Bounds)
+	return res, nil
+}
+
+// Remove deletes an object by id, the removed object is returned
+func (s *Server) Remove(_ context.Context, req *octreepb.RemoveRequest) (*octreepb.RemoveResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj, err := s.get(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	if !s.tree.Remove(*obj) {
+		return nil, status.Errorf(codes.Internal, "object %v is registered but not in the tree", obj.ID())
+	}
+	delete(s.objects, obj.ID())
+	res := objectToProto(obj)
+	s.notify(octreepb.Event_REMOVE, res, obj.Bounds)
+	return &octreepb.RemoveResponse{Object: res}, nil
+}
+
+// Move centers an object on a new position, keeping its size
+func (s *Server) Move(_ context.Context, req *octreepb.MoveRequest) (*octreepb.Object, error) {
+	p := req.GetPosition()
+	if p == nil {
+		return nil, status.Error(codes.InvalidArgument, "missing position")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj, err := s.get(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	// Octree.Move drops objects moved outside the tree, check beforehand
+	half := obj.Bounds.GetSize().Times(0.5)
+	target := volume.NewBoxMinMax(p.X-half.X, p.Y-half.Y, p.Z-half.Z, p.X+half.X, p.Y+half.Y, p.Z+half.Z)
+	if !target.Fit(s.region) {
+		return nil, status.Errorf(codes.OutOfRange, "position %v is outside the tree", p)
+	}
+	before := *cloneBox(obj.Bounds)
+	if !s.tree.Move(obj, p.X, p.Y, p.Z) {
+		return nil, status.Errorf(codes.Internal, "failed to move object %v", obj.ID())
+	}
+	res := objectToProto(obj)
+	s.notify(octreepb.Event_MOVE, res, before, obj.Bounds)
+	return res, nil
+}
+
+// Get returns an object by id
+func (s *Server) Get(_ context.Context, req *octreepb.GetRequest) (*octreepb.Object, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	obj, err := s.get(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	return objectToProto(obj), nil
+}
+
+// GetColliding returns the objects intersecting the requested bounds
+func (s *Server) GetColliding(_ context.Context, req *octreepb.GetCollidingRequest) (*octreepb.Objects, error) {
+	bounds, err := boxFromProto(req.GetBounds())
+	if err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return objectsToProto(s.tree.GetColliding(bounds)), nil
+}
+
+// Nearest returns the k objects closest to the requested point
+func (s *Server) Nearest(_ context.Context, req *octreepb.NearestRequest) (*octreepb.Objects, error) {
+	p := req.GetPoint()
+	if p == nil {
+		return nil, status.Error(codes.InvalidArgument, "missing point")
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return objectsToProto(s.tree.Nearest(*vector3.NewVector3(p.X, p.Y, p.Z), int(req.GetK()))), nil
+}
+
+// Raycast returns the objects crossed by the requested ray
+func (s *Server) Raycast(_ context.Context, req *octreepb.RaycastRequest) (*octreepb.RaycastResponse, error) {
+	origin, direction := req.GetOrigin(), req.GetDirection()
+	if origin == nil || direction == nil {
+		return nil, status.Error(codes.InvalidArgument, "missing origin or direction")
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	hits := s.tree.Raycast(
+		*vector3.NewVector3(origin.X, origin.Y, origin.Z),
+		*vector3.NewVector3(direction.X, direction.Y, direction.Z),
+		req.GetMaxDistance(),
+	)
+	res := &octreepb.RaycastResponse{}
+	for i := range hits {
+		res.Hits = append(res.Hits, &octreepb.RaycastHit{
+			Object:   objectToProto(&hits[i].Object),
+			Distance: hits[i].Distance,
+		})
+	}
+	return res, nil
+}
+
+// Watch streams the insertions, removals and moves touching the requested region
+// until the client goes away. Clients lagging more than WatchBuffer events behind are
+// disconnected with codes.ResourceExhausted rather than silently missing events.
+// The response headers are sent once subscribed, the changes made after receiving them are streamed.
+func (s *Server) Watch(req *octreepb.WatchRequest, stream octreepb.Octree_WatchServer) error {
+	region, err := boxFromProto(req.GetRegion())
+	if err != nil {
+		return err
+	}
+	w := &watcher{
+		region:  region,
+		events:  make(chan *octreepb.Event, WatchBuffer),
+		dropped: make(chan struct{}),
+	}
+	s.mu.Lock()
+	s.watchers[w] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.watchers, w)
+		s.mu.Unlock()
+	}()
+	if err := stream.SendHeader(nil); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case e := <-w.events:
+			if err := stream.Send(e); err != nil {
+				return err
+			}
+		case <-w.dropped:
+			return status.Error(codes.ResourceExhausted, "watcher is too slow, events were dropped")
+		}
+	}
+}
+
+// notify sends an event to the watchers whose region intersect any of the given bounds,
+// must be called with the write lock held
+func (s *Server) notify(t octreepb.Event_Type, obj *octreepb.Object, bounds ...volume.Box) {
+	for w := range s.watchers {
+		for _, b := range bounds {
+			if !b.Intersects(w.region) {
+				continue
+			}
+			select {
+			case w.events <- &octreepb.Event{Type: t, Object: obj}:
+			default:
+				close(w.dropped)
+				delete(s.watchers, w)
+			}
+			break
+		}
+	}
+}
+
+// get returns a registered object, must be called with the lock held
+func (s *Server) get(id uint64) (*octree.Object, error) {
+	obj, ok := s.objects[id]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "object %v not found", id)
+	}
+	return obj, nil
+}
+
+func boxFromProto(b *volume.Box) (volume.Box, error) {
+	if b == nil || b.Min == nil || b.Max == nil {
+		return volume.Box{}, status.Error(codes.InvalidArgument, "missing box or box bounds")
+	}
+	return *cloneBox(*b), nil
+}
+
+// cloneBox deep copies a box, boxes share their vectors when copied by value
+func cloneBox(b volume.Box) *volume.Box {
+	return volume.NewBoxMinMax(b.Min.X, b.Min.Y, b.Min.Z, b.Max.X, b.Max.Y, b.Max.Z)
+}
+
+func objectToProto(o *octree.Object) *octreepb.Object {
+	data, _ := o.Data.([]byte)
+	return &octreepb.Object{Id: o.ID(), Bounds: cloneBox(o.Bounds), Data: data}
+}
+
+func objectsToProto(objects []octree.Object) *octreepb.Objects {
+	res := &octreepb.Objects{Objects: make([]*octreepb.Object, 0, len(objects))}
+	for i := range objects {
+		res.Objects = append(res.Objects, objectToProto(&objects[i]))
+	}
+	return res
+}
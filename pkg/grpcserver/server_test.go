@@ -0,0 +1,161 @@
+package grpcserver
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/louis030195/octree/api/octreepb"
+	"github.com/louis030195/protometry/api/vector3"
+	"github.com/louis030195/protometry/api/volume"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// dial starts an in-memory server and returns a client connected to it
+func dial(t *testing.T) octreepb.OctreeClient {
+	lis := bufconn.Listen(1024 * 1024)
+	s := grpc.NewServer()
+	octreepb.RegisterOctreeServer(s, NewServer(volume.NewBoxOfSize(0, 0, 0, 100)))
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.Dial()
+		}),
+		grpc.WithInsecure(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return octreepb.NewOctreeClient(conn)
+}
+
+func insertCube(t *testing.T, c octreepb.OctreeClient, x, y, z, size float64, data string) *octreepb.Object {
+	obj, err := c.Insert(context.Background(), &octreepb.InsertRequest{
+		Bounds: volume.NewBoxOfSize(x, y, z, size),
+		Data:   []byte(data),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return obj
+}
+
+func expectCode(t *testing.T, code codes.Code, err error) {
+	t.Helper()
+	if status.Code(err) != code {
+		t.Fatalf("expected %v, got %v", code, err)
+	}
+}
+
+func TestServer_CRUD(t *testing.T) {
+	c := dial(t)
+	ctx := context.Background()
+
+	a := insertCube(t, c, 0, 0, 0, 2, "a")
+	b := insertCube(t, c, 20, 0, 0, 2, "b")
+	_, err := c.Insert(ctx, &octreepb.InsertRequest{Bounds: volume.NewBoxOfSize(1000, 0, 0, 2)})
+	expectCode(t, codes.OutOfRange, err)
+	_, err = c.Insert(ctx, &octreepb.InsertRequest{})
+	expectCode(t, codes.InvalidArgument, err)
+
+	got, err := c.Get(ctx, &octreepb.GetRequest{Id: a.Id})
+	if err != nil || string(got.Data) != "a" {
+		t.Fatalf("Get: %v %v", got, err)
+	}
+
+	moved, err := c.Move(ctx, &octreepb.MoveRequest{Id: a.Id, Position: vector3.NewVector3(10, 10, 10)})
+	if err != nil || moved.Bounds.Min.X != 9 || moved.Bounds.Max.X != 11 {
+		t.Fatalf("Move: %v %v", moved, err)
+	}
+	_, err = c.Move(ctx, &octreepb.MoveRequest{Id: a.Id, Position: vector3.NewVector3(1000, 0, 0)})
+	expectCode(t, codes.OutOfRange, err)
+	// A refused move must leave the object in place
+	got, err = c.Get(ctx, &octreepb.GetRequest{Id: a.Id})
+	if err != nil || got.Bounds.Min.X != 9 {
+		t.Fatalf("Get after refused move: %v %v", got, err)
+	}
+
+	colliding, err := c.GetColliding(ctx, &octreepb.GetCollidingRequest{Bounds: volume.NewBoxMinMax(8, 8, 8, 12, 12, 12)})
+	if err != nil || len(colliding.Objects) != 1 || colliding.Objects[0].Id != a.Id {
+		t.Fatalf("GetColliding: %v %v", colliding, err)
+	}
+
+	removed, err := c.Remove(ctx, &octreepb.RemoveRequest{Id: b.Id})
+	if err != nil || removed.Object.Id != b.Id {
+		t.Fatalf("Remove: %v %v", removed, err)
+	}
+	_, err = c.Remove(ctx, &octreepb.RemoveRequest{Id: b.Id})
+	expectCode(t, codes.NotFound, err)
+	_, err = c.Get(ctx, &octreepb.GetRequest{Id: b.Id})
+	expectCode(t, codes.NotFound, err)
+}
+
+func TestServer_NearestRaycast(t *testing.T) {
+	c := dial(t)
+	ctx := context.Background()
+	for i := 0.; i < 10; i++ {
+		insertCube(t, c, i*4, 0, 0, 2, "")
+	}
+	nearest, err := c.Nearest(ctx, &octreepb.NearestRequest{Point: vector3.NewVector3(-10, 0, 0), K: 3})
+	if err != nil || len(nearest.Objects) != 3 || nearest.Objects[0].Bounds.Min.X != -1 {
+		t.Fatalf("Nearest: %v %v", nearest, err)
+	}
+	hits, err := c.Raycast(ctx, &octreepb.RaycastRequest{
+		Origin:      vector3.NewVector3(-10, 0, 0),
+		Direction:   vector3.NewVector3(1, 0, 0),
+		MaxDistance: 13,
+	})
+	if err != nil || len(hits.Hits) != 2 || hits.Hits[0].Distance != 9 {
+		t.Fatalf("Raycast: %v %v", hits, err)
+	}
+}
+
+func TestServer_Watch(t *testing.T) {
+	c := dial(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := c.Watch(ctx, &octreepb.WatchRequest{Region: volume.NewBoxMinMax(0, 0, 0, 10, 10, 10)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The headers are sent once the watcher is registered, wait for them before mutating the tree
+	if _, err := stream.Header(); err != nil {
+		t.Fatal(err)
+	}
+
+	outside := insertCube(t, c, -20, -20, -20, 2, "outside")
+	inside := insertCube(t, c, 5, 5, 5, 2, "inside")
+	// Moving into the region is seen
+	if _, err := c.Move(ctx, &octreepb.MoveRequest{Id: outside.Id, Position: vector3.NewVector3(2, 2, 2)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Remove(ctx, &octreepb.RemoveRequest{Id: inside.Id}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		t  octreepb.Event_Type
+		id uint64
+	}{
+		{octreepb.Event_INSERT, inside.Id},
+		{octreepb.Event_MOVE, outside.Id},
+		{octreepb.Event_REMOVE, inside.Id},
+	}
+	for _, e := range expected {
+		ev, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ev.Type != e.t || ev.Object.Id != e.id {
+			t.Fatalf("expected %v on %v, got %v", e.t, e.id, ev)
+		}
+	}
+}
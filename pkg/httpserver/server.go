@@ -0,0 +1,406 @@
+// Package httpserver exposes named Octrees over a HTTP/JSON API.
+//
+// Routes, all bodies and responses are JSON:
+//
+//	GET    /trees                            list the tree names
+//	PUT    /trees/{tree}                     create a tree, body: box
+//	DELETE /trees/{tree}                     delete a tree
+//	POST   /trees/{tree}/objects             insert an object, body: {"bounds": box, "data": any}
+//	GET    /trees/{tree}/objects/{id}        get an object
+//	PUT    /trees/{tree}/objects/{id}        replace the bounds and/or the data of an object
+//	DELETE /trees/{tree}/objects/{id}        remove an object
+//	POST   /trees/{tree}/query/box           objects intersecting a box, body: box
+//	POST   /trees/{tree}/query/sphere        objects intersecting a sphere, body: {"center": [x,y,z], "radius": r}
+//	GET    /trees/{tree}/stats               depth histogram, number of nodes and objects, usage, memory
+//	GET    /trees/{tree}/dump[?verbose=true] the node hierarchy
+//
+// A box is {"min": [x,y,z], "max": [x,y,z]}.
+package httpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	octree "github.com/louis030195/octree/pkg"
+	"github.com/louis030195/protometry/api/vector3"
+	"github.com/louis030195/protometry/api/volume"
+)
+
+// Server is a http.Handler serving named trees, it is safe for concurrent use
+type Server struct {
+	mu    sync.RWMutex
+	trees map[string]*tree
+}
+
+// tree is an Octree and the registry used to find its objects by id
+type tree struct {
+	mu      sync.RWMutex
+	region  volume.Box
+	octree  *octree.Octree
+	objects map[uint64]*octree.Object
+}
+
+// NewServer is a Server constructor, without any tree
+func NewServer() *Server {
+	return &Server{trees: map[string]*tree{}}
+}
+
+// Box is the JSON representation of a volume.Box
+type Box struct {
+	Min [3]float64 `json:"min"`
+	Max [3]float64 `json:"max"`
+}
+
+// Object is the JSON representation of an octree.Object
+type Object struct {
+	ID     uint64          `json:"id"`
+	Bounds Box             `json:"bounds"`
+	Data   json.RawMessage `json:"data,omitempty"`
+}
+
+// Sphere is the body of a sphere query
+type Sphere struct {
+	Center [3]float64 `json:"center"`
+	Radius float64    `json:"radius"`
+}
+
+// Stats are the debugging metrics of a tree, see octree.Stats
+type Stats struct {
+	Height          int     `json:"height"`
+	Nodes           int     `json:"nodes"`
+	Leaves          int     `json:"leaves"`
+	EmptyLeaves     int     `json:"emptyLeaves"`
+	Objects         int     `json:"objects"`
+	NodesPerDepth   []int   `json:"nodesPerDepth"`
+	ObjectsPerDepth []int   `json:"objectsPerDepth"`
+	MaxObjects      int     `json:"maxObjects"`
+	Straddling      int     `json:"straddling"`
+	Usage           float64 `json:"usage"`
+	Bytes           int     `json:"bytes"`
+}
+
+// Node is the JSON representation of the node hierarchy
+type Node struct {
+	Region   Box      `json:"region"`
+	Objects  int      `json:"objects"`
+	IDs      []uint64 `json:"ids,omitempty"`
+	Children []Node   `json:"children,omitempty"`
+}
+
+// objectUpdate is the body of a PUT on an object, missing fields are left untouched
+type objectUpdate struct {
+	Bounds *Box            `json:"bounds"`
+	Data   json.RawMessage `json:"data,omitempty"`
+}
+
+// CreateTree adds an empty tree covering region, it fails if the name is taken
+func (s *Server) CreateTree(name string, region volume.Box) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.trees[name]; ok {
+		return fmt.Errorf("tree %v already exists", name)
+	}
+	s.trees[name] = &tree{
+		region:  region,
+		octree:  octree.NewOctree(&region),
+		objects: map[uint64]*octree.Object{},
+	}
+	return nil
+}
+
+// ServeHTTP routes the requests, see the package documentation
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if parts[0] != "trees" {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	switch len(parts) {
+	case 1:
+		s.handleTrees(w, r)
+		return
+	case 2:
+		s.handleTree(w, r, parts[1])
+		return
+	}
+
+	s.mu.RLock()
+	t, ok := s.trees[parts[1]]
+	s.mu.RUnlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("tree %v not found", parts[1]))
+		return
+	}
+	switch {
+	case len(parts) == 3 && parts[2] == "objects":
+		if allow(w, r, http.MethodPost) {
+			t.handleInsert(w, r)
+		}
+	case len(parts) == 4 && parts[2] == "objects":
+		id, err := strconv.ParseUint(parts[3], 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid id %v", parts[3]))
+			return
+		}
+		t.handleObject(w, r, id)
+	case len(parts) == 4 && parts[2] == "query" && parts[3] == "box":
+		if allow(w, r, http.MethodPost) {
+			t.handleQueryBox(w, r)
+		}
+	case len(parts) == 4 && parts[2] == "query" && parts[3] == "sphere":
+		if allow(w, r, http.MethodPost) {
+			t.handleQuerySphere(w, r)
+		}
+	case len(parts) == 3 && parts[2] == "stats":
+		if allow(w, r, http.MethodGet) {
+			t.handleStats(w)
+		}
+	case len(parts) == 3 && parts[2] == "dump":
+		if allow(w, r, http.MethodGet) {
+			t.handleDump(w, r)
+		}
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (s *Server) handleTrees(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodGet) {
+		return
+	}
+	s.mu.RLock()
+	names := make([]string, 0, len(s.trees))
+	for name := range s.trees {
+		names = append(names, name)
+	}
+	s.mu.RUnlock()
+	sort.Strings(names)
+	writeJSON(w, http.StatusOK, names)
+}
+
+func (s *Server) handleTree(w http.ResponseWriter, r *http.Request, name string) {
+	switch r.Method {
+	case http.MethodPut:
+		var b Box
+		if !readJSON(w, r, &b) {
+			return
+		}
+		if err := s.CreateTree(name, *b.toVolume()); err != nil {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusCreated, b)
+	case http.MethodDelete:
+		s.mu.Lock()
+		_, ok := s.trees[name]
+		delete(s.trees, name)
+		s.mu.Unlock()
+		if !ok {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("tree %v not found", name))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		allow(w, r, http.MethodPut, http.MethodDelete)
+	}
+}
+
+func (t *tree) handleInsert(w http.ResponseWriter, r *http.Request) {
+	var body Object
+	if !readJSON(w, r, &body) {
+		return
+	}
+	obj := octree.NewObject(body.Data, *body.Bounds.toVolume())
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.octree.Insert(*obj) {
+		writeError(w, http.StatusUnprocessableEntity, "bounds don't fit in the tree")
+		return
+	}
+	t.objects[obj.ID()] = obj
+	writeJSON(w, http.StatusCreated, objectToJSON(obj))
+}
+
+func (t *tree) handleObject(w http.ResponseWriter, r *http.Request, id uint64) {
+	switch r.Method {
+	case http.MethodGet:
+		t.mu.RLock()
+		defer t.mu.RUnlock()
+		if obj := t.get(w, id); obj != nil {
+			writeJSON(w, http.StatusOK, objectToJSON(obj))
+		}
+	case http.MethodPut:
+		var body objectUpdate
+		if !readJSON(w, r, &body) {
+			return
+		}
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		obj := t.get(w, id)
+		if obj == nil {
+			return
+		}
+		bounds := obj.Bounds
+		if body.Bounds != nil {
+			bounds = *body.Bounds.toVolume()
+			if !bounds.Fit(t.region) {
+				writeError(w, http.StatusUnprocessableEntity, "bounds don't fit in the tree")
+				return
+			}
+		}
+		// The tree holds its own copy of the object, replace it
+		t.octree.Remove(*obj)
+		obj.Bounds = bounds
+		if body.Data != nil {
+			obj.Data = body.Data
+		}
+		t.octree.Insert(*obj)
+		writeJSON(w, http.StatusOK, objectToJSON(obj))
+	case http.MethodDelete:
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		obj := t.get(w, id)
+		if obj == nil {
+			return
+		}
+		t.octree.Remove(*obj)
+		delete(t.objects, id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		allow(w, r, http.MethodGet, http.MethodPut, http.MethodDelete)
+	}
+}
+
+func (t *tree) handleQueryBox(w http.ResponseWriter, r *http.Request) {
+	var b Box
+	if !readJSON(w, r, &b) {
+		return
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	writeJSON(w, http.StatusOK, objectsToJSON(t.octree.GetColliding(*b.toVolume())))
+}
+
+func (t *tree) handleQuerySphere(w http.ResponseWriter, r *http.Request) {
+	var s Sphere
+	if !readJSON(w, r, &s) {
+		return
+	}
+	t.mu.RLock()
+	objects := t.octree.GetCollidingSphere(*vector3.NewVector3(s.Center[0], s.Center[1], s.Center[2]), s.Radius)
+	t.mu.RUnlock()
+	writeJSON(w, http.StatusOK, objectsToJSON(objects))
+}
+
+func (t *tree) handleStats(w http.ResponseWriter) {
+	t.mu.RLock()
+	s := t.octree.Stats()
+	t.mu.RUnlock()
+	writeJSON(w, http.StatusOK, statsToJSON(s))
+}
+
+func (t *tree) handleDump(w http.ResponseWriter, r *http.Request) {
+	verbose, _ := strconv.ParseBool(r.URL.Query().Get("verbose"))
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	writeJSON(w, http.StatusOK, dump(t.octree.Root(), verbose))
+}
+
+// get returns a registered object or writes a 404, must be called with the lock held
+func (t *tree) get(w http.ResponseWriter, id uint64) *octree.Object {
+	obj, ok := t.objects[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("object %v not found", id))
+		return nil
+	}
+	return obj
+}
+
+func dump(n *octree.Node, verbose bool) Node {
+	objects := n.GetObjects()
+	d := Node{Region: boxToJSON(n.GetRegion()), Objects: len(objects)}
+	if verbose {
+		for i := range objects {
+			d.IDs = append(d.IDs, objects[i].ID())
+		}
+	}
+	for _, c := range n.GetChildren() {
+		d.Children = append(d.Children, dump(c, verbose))
+	}
+	return d
+}
+
+func statsToJSON(s octree.Stats) Stats {
+	return Stats{
+		Height:          s.Height,
+		Nodes:           s.Nodes,
+		Leaves:          s.Leaves,
+		EmptyLeaves:     s.EmptyLeaves,
+		Objects:         s.Objects,
+		NodesPerDepth:   s.NodesPerDepth,
+		ObjectsPerDepth: s.ObjectsPerDepth,
+		MaxObjects:      s.MaxObjects,
+		Straddling:      s.Straddling,
+		Usage:           s.Usage,
+		Bytes:           s.Bytes,
+	}
+}
+
+func (b Box) toVolume() *volume.Box {
+	return volume.NewBoxMinMax(b.Min[0], b.Min[1], b.Min[2], b.Max[0], b.Max[1], b.Max[2])
+}
+
+func boxToJSON(b volume.Box) Box {
+	return Box{
+		Min: [3]float64{b.Min.X, b.Min.Y, b.Min.Z},
+		Max: [3]float64{b.Max.X, b.Max.Y, b.Max.Z},
+	}
+}
+
+func objectToJSON(o *octree.Object) Object {
+	data, _ := o.Data.(json.RawMessage)
+	return Object{ID: o.ID(), Bounds: boxToJSON(o.Bounds), Data: data}
+}
+
+func objectsToJSON(objects []octree.Object) []Object {
+	res := make([]Object, 0, len(objects))
+	for i := range objects {
+		res = append(res, objectToJSON(&objects[i]))
+	}
+	return res
+}
+
+// allow writes a 405 and returns false if the request method isn't one of methods
+func allow(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, m := range methods {
+		if r.Method == m {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(w, http.StatusMethodNotAllowed, fmt.Sprintf("method %v not allowed", r.Method))
+	return false
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid body: %v", err))
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
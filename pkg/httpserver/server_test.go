@@ -0,0 +1,169 @@
+package httpserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// do sends a JSON request to the server and decodes the response in out, if not nil
+func do(t *testing.T, ts *httptest.Server, method, path string, body, out interface{}) int {
+	t.Helper()
+	var b bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&b).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest(method, ts.URL+path, &b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if out != nil && res.StatusCode < 300 {
+		if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return res.StatusCode
+}
+
+func expectStatus(t *testing.T, exp, act int) {
+	t.Helper()
+	if exp != act {
+		t.Fatalf("expected status %v, got %v", exp, act)
+	}
+}
+
+func cube(x, y, z, size float64) Box {
+	h := size / 2
+	return Box{Min: [3]float64{x - h, y - h, z - h}, Max: [3]float64{x + h, y + h, z + h}}
+}
+
+func TestServer_Trees(t *testing.T) {
+	ts := httptest.NewServer(NewServer())
+	defer ts.Close()
+
+	expectStatus(t, http.StatusCreated, do(t, ts, http.MethodPut, "/trees/b", cube(0, 0, 0, 100), nil))
+	expectStatus(t, http.StatusCreated, do(t, ts, http.MethodPut, "/trees/a", cube(0, 0, 0, 10), nil))
+	expectStatus(t, http.StatusConflict, do(t, ts, http.MethodPut, "/trees/a", cube(0, 0, 0, 10), nil))
+	var names []string
+	expectStatus(t, http.StatusOK, do(t, ts, http.MethodGet, "/trees", nil, &names))
+	if fmt.Sprint(names) != "[a b]" {
+		t.Fatalf("unexpected trees %v", names)
+	}
+
+	// Trees are independent
+	var obj Object
+	expectStatus(t, http.StatusCreated, do(t, ts, http.MethodPost, "/trees/b/objects", Object{Bounds: cube(20, 0, 0, 1)}, &obj))
+	expectStatus(t, http.StatusUnprocessableEntity, do(t, ts, http.MethodPost, "/trees/a/objects", Object{Bounds: cube(20, 0, 0, 1)}, nil))
+	expectStatus(t, http.StatusNotFound, do(t, ts, http.MethodGet, fmt.Sprintf("/trees/a/objects/%v", obj.ID), nil, nil))
+
+	expectStatus(t, http.StatusNoContent, do(t, ts, http.MethodDelete, "/trees/b", nil, nil))
+	expectStatus(t, http.StatusNotFound, do(t, ts, http.MethodGet, fmt.Sprintf("/trees/b/objects/%v", obj.ID), nil, nil))
+	expectStatus(t, http.StatusNotFound, do(t, ts, http.MethodDelete, "/trees/b", nil, nil))
+	expectStatus(t, http.StatusMethodNotAllowed, do(t, ts, http.MethodPost, "/trees/a", nil, nil))
+}
+
+func TestServer_Objects(t *testing.T) {
+	s := NewServer()
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	if err := s.CreateTree("world", *cube(0, 0, 0, 100).toVolume()); err != nil {
+		t.Fatal(err)
+	}
+
+	var created Object
+	expectStatus(t, http.StatusCreated, do(t, ts, http.MethodPost, "/trees/world/objects", map[string]interface{}{
+		"bounds": cube(0, 0, 0, 2),
+		"data":   map[string]string{"name": "wallet"},
+	}, &created))
+	path := fmt.Sprintf("/trees/world/objects/%v", created.ID)
+
+	var got Object
+	expectStatus(t, http.StatusOK, do(t, ts, http.MethodGet, path, nil, &got))
+	if string(got.Data) != `{"name":"wallet"}` {
+		t.Fatalf("unexpected data %s", got.Data)
+	}
+
+	// Move it and check queries follow
+	expectStatus(t, http.StatusOK, do(t, ts, http.MethodPut, path, objectUpdate{Bounds: &Box{Min: [3]float64{10, 10, 10}, Max: [3]float64{12, 12, 12}}}, &got))
+	expectStatus(t, http.StatusUnprocessableEntity, do(t, ts, http.MethodPut, path, objectUpdate{Bounds: &Box{Max: [3]float64{1000, 0, 0}}}, nil))
+	var objects []Object
+	expectStatus(t, http.StatusOK, do(t, ts, http.MethodPost, "/trees/world/query/box", cube(0, 0, 0, 2), &objects))
+	if len(objects) != 0 {
+		t.Fatalf("expected no object at the origin, got %v", objects)
+	}
+	expectStatus(t, http.StatusOK, do(t, ts, http.MethodPost, "/trees/world/query/box", cube(11, 11, 11, 1), &objects))
+	if len(objects) != 1 || string(objects[0].Data) != `{"name":"wallet"}` {
+		t.Fatalf("expected the wallet, got %v", objects)
+	}
+
+	// Data only update keeps the bounds
+	expectStatus(t, http.StatusOK, do(t, ts, http.MethodPut, path, map[string]interface{}{"data": "keys"}, &got))
+	if string(got.Data) != `"keys"` || got.Bounds.Min != [3]float64{10, 10, 10} {
+		t.Fatalf("unexpected update %v", got)
+	}
+
+	expectStatus(t, http.StatusNoContent, do(t, ts, http.MethodDelete, path, nil, nil))
+	expectStatus(t, http.StatusNotFound, do(t, ts, http.MethodDelete, path, nil, nil))
+	expectStatus(t, http.StatusBadRequest, do(t, ts, http.MethodGet, "/trees/world/objects/wallet", nil, nil))
+	expectStatus(t, http.StatusBadRequest, do(t, ts, http.MethodPost, "/trees/world/objects", "not an object", nil))
+}
+
+func TestServer_QuerySphere(t *testing.T) {
+	s := NewServer()
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	if err := s.CreateTree("world", *cube(0, 0, 0, 100).toVolume()); err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range []Box{cube(3, 0, 0, 2), cube(3, 3, 3, 2), cube(-20, 0, 0, 2)} {
+		expectStatus(t, http.StatusCreated, do(t, ts, http.MethodPost, "/trees/world/objects", Object{Bounds: b}, nil))
+	}
+	var objects []Object
+	// The corner of the second cube is in the bounding box of the sphere but not in the sphere
+	expectStatus(t, http.StatusOK, do(t, ts, http.MethodPost, "/trees/world/query/sphere", Sphere{Radius: 2.5}, &objects))
+	if len(objects) != 1 || objects[0].Bounds != cube(3, 0, 0, 2) {
+		t.Fatalf("unexpected sphere query result %v", objects)
+	}
+}
+
+func TestServer_StatsDump(t *testing.T) {
+	s := NewServer()
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	if err := s.CreateTree("world", *cube(0, 0, 0, 100).toVolume()); err != nil {
+		t.Fatal(err)
+	}
+	// One object per octant, the root splits once
+	for i := 0; i < 6; i++ {
+		x, y, z := float64(i&4-2)*10, float64(i&2-1)*20, float64(i&1*2-1)*20
+		expectStatus(t, http.StatusCreated, do(t, ts, http.MethodPost, "/trees/world/objects", Object{Bounds: cube(x, y, z, 1)}, nil))
+	}
+	var stats Stats
+	expectStatus(t, http.StatusOK, do(t, ts, http.MethodGet, "/trees/world/stats", nil, &stats))
+	if stats.Height != 2 || stats.Nodes != 9 || stats.Objects != 6 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	var root Node
+	expectStatus(t, http.StatusOK, do(t, ts, http.MethodGet, "/trees/world/dump?verbose=true", nil, &root))
+	if len(root.Children) != 8 || root.Region != cube(0, 0, 0, 100) {
+		t.Fatalf("unexpected dump %+v", root)
+	}
+	ids := len(root.IDs)
+	for _, c := range root.Children {
+		ids += len(c.IDs)
+	}
+	if ids != 6 {
+		t.Fatalf("expected 6 ids in the dump, got %v", ids)
+	}
+}
@@ -0,0 +1,81 @@
+package octree
+
+import "github.com/louis030195/protometry/api/volume"
+
+// CollidingIterator is a pull-style GetColliding, e.g.
+//
+//	for it := o.IterColliding(bounds); it.Next(); {
+//		obj := it.Object()
+//	}
+//
+// The tree must not be modified during the iteration
+type CollidingIterator struct {
+	bounds volume.Box
+	mask   uint64
+	// nodes left to visit, the next one last
+	stack []*Node
+	node  *Node
+	// index of the current object in node
+	i      int
+	object *Object
+}
+
+// IterColliding returns an iterator over the objects that intersect with the specified bounds,
+// in the same order as GetColliding
+func (o *Octree) IterColliding(bounds volume.Box) *CollidingIterator {
+	return o.IterCollidingMask(bounds, AllLayers)
+}
+
+// IterCollidingMask is IterColliding restricted to the objects sharing a layer with mask
+func (o *Octree) IterCollidingMask(bounds volume.Box, mask uint64) *CollidingIterator {
+	it := &CollidingIterator{mask: mask}
+	it.Reset(o, bounds)
+	return it
+}
+
+// Reset restarts the iterator on another query, reusing its memory and keeping its mask
+func (it *CollidingIterator) Reset(o *Octree, bounds volume.Box) {
+	it.bounds = bounds
+	it.stack = append(it.stack[:0], o.root)
+	it.node = nil
+	it.object = nil
+}
+
+// Next advances to the next colliding object, it returns false once there are no more
+func (it *CollidingIterator) Next() bool {
+	for {
+		if it.node != nil {
+			for it.i < len(it.node.objects) {
+				obj := &it.node.objects[it.i]
+				it.i++
+				if obj.Bounds.Intersects(it.bounds) && matchLayers(obj.Layers, it.mask) {
+					it.object = obj
+					return true
+				}
+			}
+			it.node = nil
+		}
+		if len(it.stack) == 0 {
+			it.object = nil
+			return false
+		}
+		n := it.stack[len(it.stack)-1]
+		it.stack = it.stack[:len(it.stack)-1]
+		n.metrics().add(countVisits)
+		if !matchLayers(n.layers, it.mask) || !n.region.Intersects(it.bounds) {
+			continue
+		}
+		it.node, it.i = n, 0
+		if n.children != nil {
+			// Reversed so the first child is visited first
+			for i := len(n.children) - 1; i >= 0; i-- {
+				it.stack = append(it.stack, &n.children[i])
+			}
+		}
+	}
+}
+
+// Object returns the current object, it must not be modified
+func (it *CollidingIterator) Object() *Object {
+	return it.object
+}
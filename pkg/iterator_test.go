@@ -0,0 +1,32 @@
+package octree
+
+import (
+	"testing"
+
+	"github.com/louis030195/protometry/api/volume"
+)
+
+func TestOctree_IterColliding(t *testing.T) {
+	o := octreeRandomInsertions(t, 1000)
+	for _, bounds := range []volume.Box{
+		*volume.NewBoxOfSize(0, 0, 0, 50),
+		*volume.NewBoxOfSize(20, -10, 5, 10),
+		*volume.NewBoxOfSize(0, 0, 0, 10000),
+		*volume.NewBoxOfSize(10000, 0, 0, 1),
+	} {
+		var objects []Object
+		it := o.IterColliding(bounds)
+		for it.Next() {
+			objects = append(objects, *it.Object())
+		}
+		equals(t, o.GetColliding(bounds), objects)
+		equals(t, false, it.Next())
+		equals(t, (*Object)(nil), it.Object())
+	}
+
+	// Reused
+	it := o.IterColliding(*volume.NewBoxOfSize(0, 0, 0, 10000))
+	equals(t, true, it.Next())
+	it.Reset(o, *volume.NewBoxOfSize(10000, 0, 0, 1))
+	equals(t, false, it.Next())
+}
@@ -0,0 +1,79 @@
+package octree
+
+// Join calls f for each pair of intersecting objects, x from a and y from b, e.g. to find the overlaps
+// between static geometry and dynamic actors kept in separate trees. If f returns false, the join stops.
+// Both trees are descended at once, skipping the pairs of nodes whose regions don't intersect,
+// they may have different regions and thresholds. f must not modify the bounds of the objects nor the trees
+func Join(a, b *Octree, f func(x, y *Object) bool) {
+	JoinMask(a, b, AllLayers, AllLayers, f)
+}
+
+// JoinMask is Join restricted to the objects of a sharing a layer with maskA and the ones of b sharing a layer with maskB
+func JoinMask(a, b *Octree, maskA, maskB uint64, f func(x, y *Object) bool) {
+	join(a.root, b.root, maskA, maskB, f)
+}
+
+// join calls f on the intersecting pairs of the subtrees of x and y, returns false once f did
+func join(x, y *Node, maskX, maskY uint64, f func(x, y *Object) bool) bool {
+	if !matchLayers(x.layers, maskX) || !matchLayers(y.layers, maskY) || !x.region.Intersects(y.region) {
+		return true
+	}
+	// The largest node is split first so trees of different sizes are descended in step
+	if x.children != nil && (y.children == nil || regionVolume(x) >= regionVolume(y)) {
+		x.metrics().add(countVisits)
+		// The objects held by x against the whole subtree of y
+		for i := range x.objects {
+			obj := &x.objects[i]
+			if !matchLayers(obj.Layers, maskX) {
+				continue
+			}
+			if !y.visitColliding(obj.Bounds, maskY, func(other *Object) bool { return f(obj, other) }) {
+				return false
+			}
+		}
+		for i := range x.children {
+			if !join(&x.children[i], y, maskX, maskY, f) {
+				return false
+			}
+		}
+		return true
+	}
+	if y.children != nil {
+		y.metrics().add(countVisits)
+		for i := range y.objects {
+			obj := &y.objects[i]
+			if !matchLayers(obj.Layers, maskY) {
+				continue
+			}
+			if !x.visitColliding(obj.Bounds, maskX, func(other *Object) bool { return f(other, obj) }) {
+				return false
+			}
+		}
+		for i := range y.children {
+			if !join(x, &y.children[i], maskX, maskY, f) {
+				return false
+			}
+		}
+		return true
+	}
+	// Two leaves
+	x.metrics().add(countVisits)
+	y.metrics().add(countVisits)
+	for i := range x.objects {
+		if !matchLayers(x.objects[i].Layers, maskX) {
+			continue
+		}
+		for j := range y.objects {
+			if matchLayers(y.objects[j].Layers, maskY) && x.objects[i].Bounds.Intersects(y.objects[j].Bounds) &&
+				!f(&x.objects[i], &y.objects[j]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func regionVolume(n *Node) float64 {
+	size := n.region.GetSize()
+	return size.X * size.Y * size.Z
+}
@@ -0,0 +1,86 @@
+package octree
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/louis030195/protometry/api/volume"
+)
+
+type objectPair struct {
+	x, y uint64
+}
+
+func sortPairs(pairs []objectPair) []objectPair {
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].x == pairs[j].x {
+			return pairs[i].y < pairs[j].y
+		}
+		return pairs[i].x < pairs[j].x
+	})
+	return pairs
+}
+
+func TestJoin(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	static := NewOctree(volume.NewBoxOfSize(0, 0, 0, 100))
+	actors := NewOctree(volume.NewBoxOfSize(20, 10, 0, 40), WithSplitThreshold(2))
+	var staticObjects, actorObjects []Object
+	for i := 0; i < 300; i++ {
+		obj := *NewObjectCube(i, r.Float64()*90-45, r.Float64()*90-45, r.Float64()*90-45, r.Float64()*6+2)
+		if i%3 == 0 {
+			obj.Layers = 2
+		}
+		equals(t, true, static.Insert(obj))
+		staticObjects = append(staticObjects, obj)
+	}
+	for i := 0; i < 100; i++ {
+		obj := *NewObjectCube(i, r.Float64()*30+5, r.Float64()*30-5, r.Float64()*30-15, r.Float64()*6+2)
+		equals(t, true, actors.Insert(obj))
+		actorObjects = append(actorObjects, obj)
+	}
+
+	var expected, expectedMask []objectPair
+	for _, x := range staticObjects {
+		for _, y := range actorObjects {
+			if x.Bounds.Intersects(y.Bounds) {
+				expected = append(expected, objectPair{x.ID(), y.ID()})
+				if x.Layers == 2 {
+					expectedMask = append(expectedMask, objectPair{x.ID(), y.ID()})
+				}
+			}
+		}
+	}
+	equals(t, true, len(expected) > 10)
+
+	var pairs []objectPair
+	Join(static, actors, func(x, y *Object) bool {
+		pairs = append(pairs, objectPair{x.ID(), y.ID()})
+		return true
+	})
+	equals(t, sortPairs(expected), sortPairs(pairs))
+
+	pairs = pairs[:0]
+	JoinMask(static, actors, 2, AllLayers, func(x, y *Object) bool {
+		pairs = append(pairs, objectPair{x.ID(), y.ID()})
+		return true
+	})
+	equals(t, sortPairs(expectedMask), sortPairs(pairs))
+
+	// Swapped trees, early stop
+	n := 0
+	Join(actors, static, func(x, y *Object) bool {
+		n++
+		return n < 5
+	})
+	equals(t, 5, n)
+
+	// Disjoint trees
+	far := NewOctree(volume.NewBoxOfSize(1000, 0, 0, 10))
+	equals(t, true, far.Insert(*NewObjectCube(0, 1000, 0, 0, 1)))
+	Join(static, far, func(x, y *Object) bool {
+		t.Fatal("disjoint trees joined")
+		return true
+	})
+}
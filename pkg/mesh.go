@@ -0,0 +1,270 @@
+package octree
+
+import (
+	"container/heap"
+	"math"
+
+	"github.com/louis030195/protometry/api/vector3"
+	"github.com/louis030195/protometry/api/volume"
+)
+
+// MeshOctree indexes the triangles of a mesh for precise raycasts, closest point and point inside mesh queries,
+// e.g. on navigation meshes or terrains. The vertices of the mesh are in world coordinates, its Center is ignored.
+// The mesh must not be modified once indexed
+type MeshOctree struct {
+	mesh *volume.Mesh
+	tree *Octree
+}
+
+// MeshHit is the intersection of a ray with a triangle of a mesh
+type MeshHit struct {
+	Point vector3.Vector3
+	// Normal is the face normal, following the winding of the triangle,
+	// or the interpolated vertex normal when the mesh has one per vertex
+	Normal   vector3.Vector3
+	Triangle int
+	// U and V are the barycentric coordinates of Point, the weights of the second and the third vertices
+	U, V float64
+	// UV is the texture coordinate interpolated from the vertex ones, if the mesh has one per vertex
+	UV       vector3.Vector3
+	Distance float64
+}
+
+// NewMeshOctree indexes the triangles of mesh, the options are applied to the tree as with NewOctree.
+// The triangle index is the Data of the objects of the tree
+func NewMeshOctree(mesh *volume.Mesh, opts ...Option) *MeshOctree {
+	m := &MeshOctree{mesh: mesh}
+	triangles := make([]Object, len(mesh.Tris)/3)
+	var bounds volume.Box
+	for i := range triangles {
+		a, b, c := m.triangle(i)
+		min, max := vector3.Min(vector3.Min(a, b), c), vector3.Max(vector3.Max(a, b), c)
+		triangles[i] = *NewObject(i, volume.Box{Min: &min, Max: &max})
+		if i == 0 {
+			bounds = volume.Box{Min: min.Clone(), Max: max.Clone()}
+		} else {
+			bounds.EncapsulateBox(triangles[i].Bounds)
+		}
+	}
+	// A cube slightly larger than the mesh so the triangles on its bounds fit
+	size, center := 1., vector3.Vector3{}
+	if len(triangles) > 0 {
+		s := bounds.GetSize()
+		size = math.Max(s.X, math.Max(s.Y, s.Z))*1.01 + 1e-9
+		center = bounds.GetCenter()
+	}
+	m.tree = NewOctree(volume.NewBoxOfSize(center.X, center.Y, center.Z, size), opts...)
+	for i := range triangles {
+		m.tree.Insert(triangles[i])
+	}
+	return m
+}
+
+// Tree returns the octree of the triangles
+func (m *MeshOctree) Tree() *Octree {
+	return m.tree
+}
+
+// triangle returns the vertices of the triangle i
+func (m *MeshOctree) triangle(i int) (vector3.Vector3, vector3.Vector3, vector3.Vector3) {
+	return *m.mesh.Vertices[m.mesh.Tris[3*i]], *m.mesh.Vertices[m.mesh.Tris[3*i+1]], *m.mesh.Vertices[m.mesh.Tris[3*i+2]]
+}
+
+// interpolate returns the attribute of the triangle i at the barycentric coordinates u, v
+func (m *MeshOctree) interpolate(attributes []*vector3.Vector3, i int, u, v float64) vector3.Vector3 {
+	a, b, c := attributes[m.mesh.Tris[3*i]], attributes[m.mesh.Tris[3*i+1]], attributes[m.mesh.Tris[3*i+2]]
+	return a.Times(1 - u - v).Plus(b.Times(u)).Plus(c.Times(v))
+}
+
+// Raycast returns the first triangle crossed by the ray starting at origin, going toward direction,
+// up to maxDistance, false if there is none. A maxDistance <= 0 means the ray is infinite.
+func (m *MeshOctree) Raycast(origin, direction vector3.Vector3, maxDistance float64) (MeshHit, bool) {
+	var hit MeshHit
+	length := direction.Norm2()
+	if length == 0 {
+		return hit, false
+	}
+	direction = direction.Times(1 / length)
+	if maxDistance <= 0 {
+		maxDistance = math.Inf(1)
+	}
+	r := newRay(origin, direction, maxDistance)
+	found := false
+	m.raycast(m.tree.root, &r, func(triangle int, t, u, v float64) {
+		// Shrinking the ray prunes the nodes behind the hit
+		r.maxDistance = t
+		hit = MeshHit{Triangle: triangle, Distance: t, U: u, V: v}
+		found = true
+	})
+	if !found {
+		return hit, false
+	}
+	hit.Point = origin.Plus(direction.Times(hit.Distance))
+	a, b, c := m.triangle(hit.Triangle)
+	hit.Normal = normalize(cross(b.Minus(a), c.Minus(a)))
+	if len(m.mesh.Normals) == len(m.mesh.Vertices) {
+		hit.Normal = normalize(m.interpolate(m.mesh.Normals, hit.Triangle, hit.U, hit.V))
+	}
+	if len(m.mesh.Uvs) == len(m.mesh.Vertices) {
+		hit.UV = m.interpolate(m.mesh.Uvs, hit.Triangle, hit.U, hit.V)
+	}
+	return hit, true
+}
+
+// raycast calls f for each triangle of the subtree crossed by r, with the distance and barycentric coordinates of the hit
+func (m *MeshOctree) raycast(n *Node, r *ray, f func(triangle int, t, u, v float64)) {
+	n.metrics().add(countVisits)
+	if _, ok := r.intersects(n.region); !ok {
+		return
+	}
+	for i := range n.objects {
+		if _, ok := r.intersects(n.objects[i].Bounds); !ok {
+			continue
+		}
+		triangle := n.objects[i].Data.(int)
+		a, b, c := m.triangle(triangle)
+		if t, u, v, ok := rayTriangle(r, a, b, c); ok {
+			f(triangle, t, u, v)
+		}
+	}
+	if n.children != nil {
+		for i := range n.children {
+			m.raycast(&n.children[i], r, f)
+		}
+	}
+}
+
+// rayTriangle is the Möller–Trumbore intersection, it returns the distance along the ray
+// and the barycentric coordinates of the hit
+func rayTriangle(r *ray, a, b, c vector3.Vector3) (t, u, v float64, ok bool) {
+	const epsilon = 1e-12
+	e1, e2 := b.Minus(a), c.Minus(a)
+	p := cross(r.direction, e2)
+	det := e1.Dot(p)
+	// Parallel to the triangle
+	if math.Abs(det) < epsilon {
+		return 0, 0, 0, false
+	}
+	inv := 1 / det
+	s := r.origin.Minus(a)
+	u = s.Dot(p) * inv
+	if u < 0 || u > 1 {
+		return 0, 0, 0, false
+	}
+	q := cross(s, e1)
+	v = r.direction.Dot(q) * inv
+	if v < 0 || u+v > 1 {
+		return 0, 0, 0, false
+	}
+	t = e2.Dot(q) * inv
+	if t < 0 || t > r.maxDistance {
+		return 0, 0, 0, false
+	}
+	return t, u, v, true
+}
+
+// MeshPoint is a point on the surface of a mesh
+type MeshPoint struct {
+	Point    vector3.Vector3
+	Triangle int
+	// Distance to the query point
+	Distance float64
+}
+
+// ClosestPoint returns the point of the surface of the mesh closest to point, false if the mesh has no triangle
+func (m *MeshOctree) ClosestPoint(point vector3.Vector3) (MeshPoint, bool) {
+	// Best-first search as Nearest, the objects being queued with their exact distance to point,
+	// never less than the one of their bounds
+	q := &nearestQueue{{node: m.tree.root, distance: sqDistancePointBox(point, m.tree.root.region)}}
+	for q.Len() > 0 {
+		item := heap.Pop(q).(nearestItem)
+		if item.node == nil {
+			triangle := item.object.Data.(int)
+			a, b, c := m.triangle(triangle)
+			return MeshPoint{Point: closestPointTriangle(point, a, b, c), Triangle: triangle, Distance: math.Sqrt(item.distance)}, true
+		}
+		n := item.node
+		n.metrics().add(countVisits)
+		for i := range n.objects {
+			a, b, c := m.triangle(n.objects[i].Data.(int))
+			p := closestPointTriangle(point, a, b, c)
+			heap.Push(q, nearestItem{object: &n.objects[i], distance: p.Minus(point).Norm()})
+		}
+		if n.children != nil {
+			for i := range n.children {
+				heap.Push(q, nearestItem{node: &n.children[i], distance: sqDistancePointBox(point, n.children[i].region)})
+			}
+		}
+	}
+	return MeshPoint{}, false
+}
+
+// closestPointTriangle returns the point of the triangle abc closest to p,
+// from Real-Time Collision Detection, Christer Ericson, 5.1.5
+func closestPointTriangle(p, a, b, c vector3.Vector3) vector3.Vector3 {
+	ab, ac, ap := b.Minus(a), c.Minus(a), p.Minus(a)
+	d1, d2 := ab.Dot(ap), ac.Dot(ap)
+	// Vertex region of a
+	if d1 <= 0 && d2 <= 0 {
+		return a
+	}
+	bp := p.Minus(b)
+	d3, d4 := ab.Dot(bp), ac.Dot(bp)
+	// Vertex region of b
+	if d3 >= 0 && d4 <= d3 {
+		return b
+	}
+	// Edge region of ab
+	if vc := d1*d4 - d3*d2; vc <= 0 && d1 >= 0 && d3 <= 0 {
+		return a.Plus(ab.Times(d1 / (d1 - d3)))
+	}
+	cp := p.Minus(c)
+	d5, d6 := ab.Dot(cp), ac.Dot(cp)
+	// Vertex region of c
+	if d6 >= 0 && d5 <= d6 {
+		return c
+	}
+	// Edge region of ac
+	if vb := d5*d2 - d1*d6; vb <= 0 && d2 >= 0 && d6 <= 0 {
+		return a.Plus(ac.Times(d2 / (d2 - d6)))
+	}
+	// Edge region of bc
+	if va := d3*d6 - d5*d4; va <= 0 && d4-d3 >= 0 && d5-d6 >= 0 {
+		return b.Plus(c.Minus(b).Times((d4 - d3) / ((d4 - d3) + (d5 - d6))))
+	}
+	// Inside the face
+	va, vb, vc := d3*d6-d5*d4, d5*d2-d1*d6, d1*d4-d3*d2
+	denom := 1 / (va + vb + vc)
+	return a.Plus(ab.Times(vb * denom)).Plus(ac.Times(vc * denom))
+}
+
+// insideDirection is skewed so the rays of Inside are unlikely to cross the mesh on an edge or a vertex
+var insideDirection = normalize(*vector3.NewVector3(1, math.Sqrt2*1e-3, math.Sqrt(3)*1e-3))
+
+// Inside returns whether point is inside the mesh, which must be closed, by counting the triangles
+// crossed by a ray going out of it
+func (m *MeshOctree) Inside(point vector3.Vector3) bool {
+	if !m.tree.root.region.Contains(point) {
+		return false
+	}
+	r := newRay(point, insideDirection, math.Inf(1))
+	crossings := 0
+	m.raycast(m.tree.root, &r, func(int, float64, float64, float64) {
+		crossings++
+	})
+	return crossings%2 == 1
+}
+
+// cross is the cross product, vector3.Cross gets the z component wrong
+func cross(a, b vector3.Vector3) vector3.Vector3 {
+	return *vector3.NewVector3(a.Y*b.Z-a.Z*b.Y, a.Z*b.X-a.X*b.Z, a.X*b.Y-a.Y*b.X)
+}
+
+// normalize returns the unit vector of v, the zero vector if v is zero, vector3.Normalize divides by the square root of the norm
+func normalize(v vector3.Vector3) vector3.Vector3 {
+	length := v.Norm2()
+	if length == 0 {
+		return v
+	}
+	return v.Times(1 / length)
+}
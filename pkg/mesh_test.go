@@ -0,0 +1,114 @@
+package octree
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/louis030195/protometry/api/vector3"
+	"github.com/louis030195/protometry/api/volume"
+)
+
+// terrain returns a height field of size by size cells
+func terrain(r *rand.Rand, size int) *volume.Mesh {
+	mesh := &volume.Mesh{}
+	for x := 0; x <= size; x++ {
+		for y := 0; y <= size; y++ {
+			v := vector3.NewVector3(float64(x)*2, float64(y)*2, r.Float64()*3)
+			mesh.Vertices = append(mesh.Vertices, v)
+			mesh.Uvs = append(mesh.Uvs, v.Clone())
+		}
+	}
+	for x := 0; x < size; x++ {
+		for y := 0; y < size; y++ {
+			i := int32(x*(size+1) + y)
+			mesh.Tris = append(mesh.Tris, i, i+int32(size)+1, i+1, i+1, i+int32(size)+1, i+int32(size)+2)
+		}
+	}
+	return mesh
+}
+
+func near(a, b vector3.Vector3) bool {
+	return a.Minus(b).Norm2() < 1e-9
+}
+
+func TestMeshOctree_Cube(t *testing.T) {
+	m := NewMeshOctree(volume.NewMeshSquareCuboid(2, true))
+	equals(t, 12, m.Tree().getNumberOfObjects())
+
+	hit, ok := m.Raycast(*vector3.NewVector3(-5, 0.2, 0.3), *vector3.NewVector3(2, 0, 0), 0)
+	equals(t, true, ok)
+	equals(t, true, near(*vector3.NewVector3(-1, 0.2, 0.3), hit.Point))
+	equals(t, true, near(*vector3.NewVector3(-1, 0, 0), hit.Normal))
+	equals(t, 4., hit.Distance)
+	// Left face
+	equals(t, true, hit.Triangle == 6 || hit.Triangle == 7)
+	a, b, c := m.triangle(hit.Triangle)
+	equals(t, true, near(hit.Point, a.Times(1-hit.U-hit.V).Plus(b.Times(hit.U)).Plus(c.Times(hit.V))))
+
+	_, ok = m.Raycast(*vector3.NewVector3(-5, 0.2, 0.3), *vector3.NewVector3(2, 0, 0), 3)
+	equals(t, false, ok)
+	_, ok = m.Raycast(*vector3.NewVector3(-5, 2, 0), *vector3.NewVector3(1, 0, 0), 0)
+	equals(t, false, ok)
+	// From inside
+	hit, ok = m.Raycast(vector3.Vector3{}, *vector3.NewVector3(0, 0, 1), 0)
+	equals(t, true, ok)
+	equals(t, 1., hit.Distance)
+
+	p, ok := m.ClosestPoint(*vector3.NewVector3(5, 0, 0))
+	equals(t, true, ok)
+	equals(t, true, near(*vector3.NewVector3(1, 0, 0), p.Point))
+	equals(t, 4., p.Distance)
+	p, _ = m.ClosestPoint(*vector3.NewVector3(0, 0, 0.5))
+	equals(t, true, near(*vector3.NewVector3(0, 0, 1), p.Point))
+	p, _ = m.ClosestPoint(*vector3.NewVector3(3, 3, 3))
+	equals(t, true, near(*vector3.NewVector3(1, 1, 1), p.Point))
+
+	equals(t, true, m.Inside(vector3.Vector3{}))
+	equals(t, true, m.Inside(*vector3.NewVector3(0.9, -0.9, 0.9)))
+	equals(t, false, m.Inside(*vector3.NewVector3(1.1, 0, 0)))
+	equals(t, false, m.Inside(*vector3.NewVector3(-3, 0, 0)))
+
+	_, ok = NewMeshOctree(&volume.Mesh{}).ClosestPoint(vector3.Vector3{})
+	equals(t, false, ok)
+}
+
+func TestMeshOctree_Terrain(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	mesh := terrain(r, 16)
+	m := NewMeshOctree(mesh)
+	equals(t, nil, m.Tree().Validate())
+	triangles := len(mesh.Tris) / 3
+
+	for i := 0; i < 100; i++ {
+		origin := *vector3.NewVector3(r.Float64()*32, r.Float64()*32, 10)
+		direction := *vector3.NewVector3(r.Float64()-0.5, r.Float64()-0.5, -1)
+		// Brute force
+		ray := newRay(origin, normalize(direction), math.Inf(1))
+		best, bestT := -1, math.Inf(1)
+		for j := 0; j < triangles; j++ {
+			a, b, c := m.triangle(j)
+			if d, _, _, ok := rayTriangle(&ray, a, b, c); ok && d < bestT {
+				best, bestT = j, d
+			}
+		}
+		hit, ok := m.Raycast(origin, direction, 0)
+		equals(t, best >= 0, ok)
+		if ok {
+			equals(t, true, math.Abs(bestT-hit.Distance) < 1e-9)
+			equals(t, true, near(hit.Point, hit.UV))
+			equals(t, true, hit.Normal.Z > 0)
+		}
+
+		point := *vector3.NewVector3(r.Float64()*40-4, r.Float64()*40-4, r.Float64()*10-3)
+		bestD := math.Inf(1)
+		for j := 0; j < triangles; j++ {
+			a, b, c := m.triangle(j)
+			bestD = math.Min(bestD, closestPointTriangle(point, a, b, c).Distance(point))
+		}
+		p, ok := m.ClosestPoint(point)
+		equals(t, true, ok)
+		equals(t, true, math.Abs(bestD-p.Distance) < 1e-9)
+		equals(t, true, math.Abs(p.Point.Distance(point)-p.Distance) < 1e-9)
+	}
+}
@@ -0,0 +1,142 @@
+package octree
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Query kinds, the query label of the latency histogram
+const (
+	queryColliding = iota
+	querySphere
+	queryNearest
+	queryRaycast
+	querySweep
+	queryKinds
+)
+
+var queryNames = [queryKinds]string{"colliding", "sphere", "nearest", "raycast", "sweep"}
+
+// Counters
+const (
+	countInserts = iota
+	countRemoves
+	countMoves
+	countSplits
+	countMerges
+	countVisits
+	counterKinds
+)
+
+var counterNames = [counterKinds]struct{ name, help string }{
+	{"octree_inserts_total", "Number of objects inserted."},
+	{"octree_removes_total", "Number of objects removed."},
+	{"octree_moves_total", "Number of objects moved."},
+	{"octree_splits_total", "Number of nodes split into eight children."},
+	{"octree_merges_total", "Number of nodes merged with their children."},
+	{"octree_query_visits_total", "Number of nodes visited by queries."},
+}
+
+// latencyBuckets are the upper bounds of the latency histogram, in seconds
+var latencyBuckets = [...]float64{1e-6, 5e-6, 1e-5, 5e-5, 1e-4, 5e-4, 1e-3, 5e-3, 1e-2, 5e-2, 1e-1, 1}
+
+// Metrics counts the operations of the trees it is attached to with WithMetrics,
+// it is safe to read them while the trees are modified.
+// Metrics implements http.Handler, serving them in the Prometheus text exposition format.
+type Metrics struct {
+	// Accessed atomically, kept first for 64 bits alignment
+	counters [counterKinds]uint64
+	queries  [queryKinds]latencyHistogram
+}
+
+type latencyHistogram struct {
+	// Cumulative counts are computed when writing, the last bucket is +Inf
+	buckets [len(latencyBuckets) + 1]uint64
+	count   uint64
+	sumNs   uint64
+}
+
+// NewMetrics returns zeroed metrics
+func NewMetrics() *Metrics {
+	return &Metrics{}
+}
+
+// WithMetrics records the operations of the tree in m
+func WithMetrics(m *Metrics) Option {
+	return func(o *Octree) {
+		o.metrics = m
+	}
+}
+
+// metrics returns the metrics of the tree of n, nil when not instrumented
+func (n *Node) metrics() *Metrics {
+	if n.tree == nil {
+		return nil
+	}
+	return n.tree.metrics
+}
+
+// add increments counter, m may be nil
+func (m *Metrics) add(counter int) {
+	if m != nil {
+		atomic.AddUint64(&m.counters[counter], 1)
+	}
+}
+
+// count increments counter when ok, returns ok
+func (m *Metrics) count(counter int, ok bool) bool {
+	if ok {
+		m.add(counter)
+	}
+	return ok
+}
+
+// observe records the latency of a query started at start, meant to be deferred
+func (m *Metrics) observe(query int, start time.Time) {
+	d := time.Since(start)
+	h := &m.queries[query]
+	i := 0
+	for i < len(latencyBuckets) && d.Seconds() > latencyBuckets[i] {
+		i++
+	}
+	atomic.AddUint64(&h.buckets[i], 1)
+	atomic.AddUint64(&h.count, 1)
+	atomic.AddUint64(&h.sumNs, uint64(d.Nanoseconds()))
+}
+
+// WritePrometheus writes the metrics in the Prometheus text exposition format
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for i, c := range counterNames {
+		fmt.Fprintf(bw, "# HELP %v %v\n# TYPE %v counter\n%v %v\n", c.name, c.help, c.name, c.name, atomic.LoadUint64(&m.counters[i]))
+	}
+
+	fmt.Fprintf(bw, "# HELP octree_query_duration_seconds Latency of the queries.\n# TYPE octree_query_duration_seconds histogram\n")
+	for i := range m.queries {
+		h := &m.queries[i]
+		cumulative := uint64(0)
+		for j := range h.buckets {
+			cumulative += atomic.LoadUint64(&h.buckets[j])
+			le := "+Inf"
+			if j < len(latencyBuckets) {
+				le = strconv.FormatFloat(latencyBuckets[j], 'g', -1, 64)
+			}
+			fmt.Fprintf(bw, "octree_query_duration_seconds_bucket{query=%q,le=%q} %v\n", queryNames[i], le, cumulative)
+		}
+		sum := float64(atomic.LoadUint64(&h.sumNs)) / float64(time.Second)
+		fmt.Fprintf(bw, "octree_query_duration_seconds_sum{query=%q} %v\n", queryNames[i], strconv.FormatFloat(sum, 'g', -1, 64))
+		fmt.Fprintf(bw, "octree_query_duration_seconds_count{query=%q} %v\n", queryNames[i], atomic.LoadUint64(&h.count))
+	}
+	return bw.Flush()
+}
+
+// ServeHTTP serves the metrics to a Prometheus scraper
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WritePrometheus(w)
+}
@@ -0,0 +1,70 @@
+package octree
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/louis030195/protometry/api/vector3"
+	"github.com/louis030195/protometry/api/volume"
+)
+
+func TestMetrics(t *testing.T) {
+	m := NewMetrics()
+	o := NewOctree(volume.NewBoxOfSize(0, 0, 0, 8), WithMetrics(m))
+	objects := make([]*Object, CAPACITY+1)
+	for i := range objects {
+		objects[i] = NewObjectCube(i, float64(i%2)*4-2, 2, 2, 1)
+		equals(t, true, o.Insert(*objects[i]))
+	}
+	equals(t, false, o.Insert(*NewObjectCube(0, 10, 0, 0, 1)))
+	equals(t, true, o.Move(objects[0], 2, 2, 2))
+	equals(t, false, o.Move(objects[0], 20, 2, 2))
+	// The object is now out of the tree
+	equals(t, false, o.Remove(*objects[0]))
+	equals(t, true, o.Remove(*objects[1]))
+
+	o.GetColliding(*volume.NewBoxOfSize(-2, -2, -2, 1))
+	o.GetCollidingSphere(*vector3.NewVector3(2, 2, 2), 1)
+	o.Nearest(*vector3.NewVector3(2, 2, 2), 1)
+	o.Raycast(*vector3.NewVector3(-4, -4, -4), *vector3.NewVector3(1, 1, 1), 0)
+	o.Sweep(*volume.NewBoxOfSize(-2, -2, -2, 1), *vector3.NewVector3(4, 4, 4))
+
+	equals(t, uint64(CAPACITY+1), m.counters[countInserts])
+	equals(t, uint64(1), m.counters[countRemoves])
+	equals(t, uint64(1), m.counters[countMoves])
+	// A split on the sixth insert, the moves remove before inserting
+	// so the first one merges and splits again, the second one merges
+	equals(t, uint64(2), m.counters[countSplits])
+	equals(t, uint64(2), m.counters[countMerges])
+	// The tree is a single leaf again, one visit per query
+	equals(t, uint64(5), m.counters[countVisits])
+	for i := range m.queries {
+		equals(t, uint64(1), m.queries[i].count)
+	}
+
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	equals(t, "text/plain; version=0.0.4; charset=utf-8", w.Header().Get("Content-Type"))
+	body, _ := ioutil.ReadAll(w.Body)
+	text := string(body)
+	for _, line := range []string{
+		"# TYPE octree_inserts_total counter\noctree_inserts_total 6\n",
+		"octree_splits_total 2\n",
+		"# TYPE octree_query_duration_seconds histogram\n",
+		`octree_query_duration_seconds_bucket{query="raycast",le="+Inf"} 1` + "\n",
+		`octree_query_duration_seconds_count{query="colliding"} 1` + "\n",
+	} {
+		if !strings.Contains(text, line) {
+			t.Fatalf("%q not found in\n%v", line, text)
+		}
+	}
+
+	// Trees without metrics aren't instrumented, nodes built by hand neither
+	o = NewOctree(volume.NewBoxOfSize(0, 0, 0, 8))
+	equals(t, true, o.Insert(*NewObjectCube(0, 1, 1, 1, 1)))
+	n := Node{region: *volume.NewBoxOfSize(0, 0, 0, 8)}
+	equals(t, true, n.insert(*NewObjectCube(0, 1, 1, 1, 1)))
+	equals(t, 1, len(n.appendColliding(nil, n.region, AllLayers)))
+}
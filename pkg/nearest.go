@@ -0,0 +1,100 @@
+package octree
+
+import (
+	"container/heap"
+	"math"
+	"time"
+
+	"github.com/louis030195/protometry/api/vector3"
+	"github.com/louis030195/protometry/api/volume"
+)
+
+// Nearest returns the k objects closest to point, sorted by increasing distance.
+// The distance to an object is the distance between point and its bounds,
+// so any object containing point is at distance 0.
+func (o *Octree) Nearest(point vector3.Vector3, k int) []Object {
+	return o.NearestMask(point, k, AllLayers)
+}
+
+// NearestMask is Nearest restricted to the objects sharing a layer with mask
+func (o *Octree) NearestMask(point vector3.Vector3, k int, mask uint64) []Object {
+	if k <= 0 {
+		return nil
+	}
+	if o.metrics != nil {
+		defer o.metrics.observe(queryNearest, time.Now())
+	}
+	var objects []Object
+	// Best-first search: nodes and objects share a single queue ordered by distance,
+	// an object popped from the queue can't be beaten by anything left in it
+	q := &nearestQueue{{node: o.root, distance: sqDistancePointBox(point, o.root.region)}}
+	for q.Len() > 0 && len(objects) < k {
+		item := heap.Pop(q).(nearestItem)
+		if item.node == nil {
+			objects = append(objects, *item.object)
+			continue
+		}
+		n := item.node
+		n.metrics().add(countVisits)
+		for i := range n.objects {
+			if !matchLayers(n.objects[i].Layers, mask) {
+				continue
+			}
+			heap.Push(q, nearestItem{
+				object:   &n.objects[i],
+				distance: sqDistancePointBox(point, n.objects[i].Bounds),
+			})
+		}
+		if n.children != nil {
+			for i := range n.children {
+				if !matchLayers(n.children[i].layers, mask) {
+					continue
+				}
+				heap.Push(q, nearestItem{
+					node:     &n.children[i],
+					distance: sqDistancePointBox(point, n.children[i].region),
+				})
+			}
+		}
+	}
+	return objects
+}
+
+// sqDistancePointBox returns the squared distance between a point and a box, 0 if the point is inside
+func sqDistancePointBox(p vector3.Vector3, b volume.Box) float64 {
+	dx := math.Max(math.Max(b.Min.X-p.X, 0), p.X-b.Max.X)
+	dy := math.Max(math.Max(b.Min.Y-p.Y, 0), p.Y-b.Max.Y)
+	dz := math.Max(math.Max(b.Min.Z-p.Z, 0), p.Z-b.Max.Z)
+	return dx*dx + dy*dy + dz*dz
+}
+
+// nearestItem is either a node or an object waiting to be visited
+type nearestItem struct {
+	node     *Node
+	object   *Object
+	distance float64
+}
+
+// nearestQueue is a min-heap of nearestItem, implements heap.Interface
+type nearestQueue []nearestItem
+
+func (q nearestQueue) Len() int { return len(q) }
+
+func (q nearestQueue) Less(i, j int) bool {
+	if q[i].distance == q[j].distance {
+		// On ties, objects come first so they are returned before descending further
+		return q[i].node == nil && q[j].node != nil
+	}
+	return q[i].distance < q[j].distance
+}
+
+func (q nearestQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *nearestQueue) Push(x interface{}) { *q = append(*q, x.(nearestItem)) }
+
+func (q *nearestQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
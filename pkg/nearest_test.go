@@ -0,0 +1,42 @@
+package octree
+
+import (
+	"math"
+	"sort"
+	"testing"
+
+	"github.com/louis030195/protometry/api/vector3"
+	"github.com/louis030195/protometry/api/volume"
+)
+
+func TestOctree_Nearest(t *testing.T) {
+	o := NewOctree(volume.NewBoxOfSize(0, 0, 0, 100))
+	equals(t, true, o.Insert(*NewObjectCube(0, 10, 0, 0, 1)))
+	equals(t, true, o.Insert(*NewObjectCube(1, 20, 0, 0, 1)))
+	equals(t, true, o.Insert(*NewObjectCube(2, -5, 0, 0, 1)))
+	equals(t, 0, len(o.Nearest(*vector3.NewVector3Zero(), 0)))
+	nearest := o.Nearest(*vector3.NewVector3Zero(), 2)
+	equals(t, 2, len(nearest))
+	equals(t, 2, nearest[0].Data)
+	equals(t, 0, nearest[1].Data)
+	// Asking for more than there is
+	equals(t, 3, len(o.Nearest(*vector3.NewVector3Zero(), 10)))
+}
+
+func TestOctree_NearestRandom(t *testing.T) {
+	size := 1000.
+	o := octreeRandomInsertions(t, size)
+	p := vector3.RandomSpherePoint(*vector3.NewVector3Zero(), size/2)
+	k := 10
+	nearest := o.Nearest(p, k)
+	equals(t, k, len(nearest))
+
+	// Brute force
+	all := o.GetAllObjects()
+	sort.Slice(all, func(i, j int) bool {
+		return sqDistancePointBox(p, all[i].Bounds) < sqDistancePointBox(p, all[j].Bounds)
+	})
+	for i := range nearest {
+		equals(t, true, math.Abs(sqDistancePointBox(p, all[i].Bounds)-sqDistancePointBox(p, nearest[i].Bounds)) < 1e-9)
+	}
+}
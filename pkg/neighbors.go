@@ -0,0 +1,137 @@
+package octree
+
+import "github.com/louis030195/protometry/api/vector3"
+
+// Direction is the offset of a neighbour, each component being -1, 0 or 1.
+// One non zero component is a face neighbour, two an edge neighbour and three a corner neighbour
+type Direction struct {
+	X, Y, Z int
+}
+
+// Directions are the 26 directions of the neighbours, faces first then edges then corners
+var Directions = func() []Direction {
+	var directions []Direction
+	for nonZero := 1; nonZero <= 3; nonZero++ {
+		for x := -1; x <= 1; x++ {
+			for y := -1; y <= 1; y++ {
+				for z := -1; z <= 1; z++ {
+					if x*x+y*y+z*z == nonZero {
+						directions = append(directions, Direction{x, y, z})
+					}
+				}
+			}
+		}
+	}
+	return directions
+}()
+
+// Root returns the root node of the tree.
+// Nodes are stable handles, they stay valid until merged into their parent or the tree is rebuilt
+func (o *Octree) Root() *Node {
+	return o.root
+}
+
+// Leaves returns the leaves of the tree, in the DFS order
+func (o *Octree) Leaves() []*Node {
+	return o.root.leaves()
+}
+
+// LeafAt returns the leaf containing point, the first one in the DFS order if point is on a boundary,
+// nil if point is outside the tree
+func (o *Octree) LeafAt(point vector3.Vector3) *Node {
+	n := o.root
+	if !n.region.Contains(point) {
+		return nil
+	}
+	for n.children != nil {
+		for i := range n.children {
+			if n.children[i].region.Contains(point) {
+				n = &n.children[i]
+				break
+			}
+		}
+	}
+	return n
+}
+
+// Neighbors returns the leaves adjacent to leaf in direction: for a face neighbour, the leaves sharing
+// a part of the face of leaf, for an edge or corner neighbour, the ones sharing a part of the edge or the corner only.
+// They may be the same size as leaf, larger or smaller. It returns nil on the boundary of the tree
+func (o *Octree) Neighbors(leaf *Node, direction Direction) []*Node {
+	d := [3]int{direction.X, direction.Y, direction.Z}
+	if d == [3]int{} {
+		return nil
+	}
+	for _, c := range d {
+		if c < -1 || c > 1 {
+			return nil
+		}
+	}
+	min, max := axes(leaf)
+	// Climb to the first ancestor covering the other side of the face, edge or corner
+	ancestor := leaf.parent
+	for ancestor != nil && !covers(ancestor, d, min, max) {
+		ancestor = ancestor.parent
+	}
+	if ancestor == nil {
+		return nil
+	}
+	var neighbors []*Node
+	ancestor.walk(0, func(n *Node, _ int) bool {
+		if !covers(n, d, min, max) {
+			return false
+		}
+		if n.children == nil && adjacent(n, d, min, max) {
+			neighbors = append(neighbors, n)
+		}
+		return true
+	})
+	return neighbors
+}
+
+// axes returns the min and max of the region of n per axis
+func axes(n *Node) ([3]float64, [3]float64) {
+	return [3]float64{n.region.Min.X, n.region.Min.Y, n.region.Min.Z}, [3]float64{n.region.Max.X, n.region.Max.Y, n.region.Max.Z}
+}
+
+// covers returns whether n may hold neighbours of the leaf from min to max in direction d:
+// n reaches across the face, edge or corner and overlaps the leaf along the other axes
+func covers(n *Node, d [3]int, min, max [3]float64) bool {
+	nMin, nMax := axes(n)
+	for i := range d {
+		switch d[i] {
+		case 1:
+			if nMin[i] > max[i] || nMax[i] <= max[i] {
+				return false
+			}
+		case -1:
+			if nMax[i] < min[i] || nMin[i] >= min[i] {
+				return false
+			}
+		default:
+			if nMin[i] >= max[i] || nMax[i] <= min[i] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// adjacent returns whether the leaf n touches the leaf from min to max in direction d.
+// The regions are split at the same coordinates so they are compared exactly
+func adjacent(n *Node, d [3]int, min, max [3]float64) bool {
+	nMin, nMax := axes(n)
+	for i := range d {
+		switch d[i] {
+		case 1:
+			if nMin[i] != max[i] {
+				return false
+			}
+		case -1:
+			if nMax[i] != min[i] {
+				return false
+			}
+		}
+	}
+	return true
+}
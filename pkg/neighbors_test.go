@@ -0,0 +1,74 @@
+package octree
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/louis030195/protometry/api/vector3"
+	"github.com/louis030195/protometry/api/volume"
+)
+
+// refinedTree returns a tree whose leaves are smaller around the corner -x -y -z
+func refinedTree(t *testing.T) *Octree {
+	o := NewOctree(volume.NewBoxOfSize(0, 0, 0, 16), WithSplitThreshold(2))
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 60; i++ {
+		p := vector3.NewVector3(r.ExpFloat64()*2-7.9, r.ExpFloat64()*2-7.9, r.ExpFloat64()*2-7.9)
+		equals(t, true, o.Insert(*NewObjectCube(i, p.X, p.Y, p.Z, 0.01)))
+	}
+	return o
+}
+
+func TestDirections(t *testing.T) {
+	equals(t, 26, len(Directions))
+	equals(t, Direction{-1, 0, 0}, Directions[0])
+	equals(t, Direction{1, 1, 1}, Directions[25])
+}
+
+func TestOctree_Neighbors(t *testing.T) {
+	o := refinedTree(t)
+	equals(t, nil, o.Validate())
+	leaves := o.Leaves()
+	equals(t, true, len(leaves) > 50)
+	equals(t, true, o.getHeight() > 4)
+
+	// Brute force
+	touches := func(leaf, other *Node, d Direction) bool {
+		min, max := axes(leaf)
+		oMin, oMax := axes(other)
+		for i, c := range [3]int{d.X, d.Y, d.Z} {
+			switch {
+			case c == 1 && oMin[i] != max[i], c == -1 && oMax[i] != min[i], c == 0 && (oMin[i] >= max[i] || oMax[i] <= min[i]):
+				return false
+			}
+		}
+		return true
+	}
+	for _, leaf := range leaves {
+		equals(t, true, leaf.GetChildren() == nil)
+		for _, d := range Directions {
+			var expected []*Node
+			for _, other := range leaves {
+				if touches(leaf, other, d) {
+					expected = append(expected, other)
+				}
+			}
+			equals(t, expected, o.Neighbors(leaf, d))
+		}
+	}
+
+	// The leaf at the max corner is a child of the root, it has smaller neighbours below
+	leaf := o.LeafAt(*vector3.NewVector3(7, 7, 7))
+	equals(t, 1, leaf.Depth())
+	equals(t, o.Root(), leaf.Parent())
+	equals(t, 0, len(o.Neighbors(leaf, Direction{1, 0, 0})))
+	equals(t, []*Node{o.Root().GetChildren()[3]}, o.Neighbors(leaf, Direction{-1, 0, 0}))
+	corner := o.Neighbors(leaf, Direction{-1, -1, -1})
+	equals(t, 1, len(corner))
+	small := corner[0]
+	equals(t, true, small.Depth() > 1)
+	equals(t, vector3.Vector3{}, *small.GetRegion().Max)
+	equals(t, []*Node{leaf}, o.Neighbors(small, Direction{1, 1, 1}))
+	equals(t, 0, len(o.Neighbors(leaf, Direction{})))
+	equals(t, (*Node)(nil), o.LeafAt(*vector3.NewVector3(9, 0, 0)))
+}
@@ -2,6 +2,7 @@ package octree
 
 import (
 	"fmt"
+    "github.com/louis030195/protometry/api/vector3"
     "github.com/louis030195/protometry/api/volume"
 )
 
@@ -15,6 +16,26 @@ type Node struct {
 	objects  []Object
 	region   volume.Box
 	children *[8]Node
+	// tree is the Octree of the node, holding its configuration, nil for nodes built outside NewOctree
+	tree *Octree
+	// layers is the union of the layers of the objects of the subtree, it may contain layers
+	// no longer used until the next removal in the subtree
+	layers uint64
+	// aggregates are the values of the aggregators of the tree for the objects of the subtree
+	aggregates []interface{}
+	// parent is nil for the root
+	parent *Node
+	depth  int
+}
+
+// Depth returns the depth of the node, 0 for the root
+func (n *Node) Depth() int {
+	return n.depth
+}
+
+// Parent returns the parent of the node, nil for the root
+func (n *Node) Parent() *Node {
+	return n.parent
 }
 
 // Insert ...
@@ -23,9 +44,11 @@ func (n *Node) insert(object Object) bool {
 	if !object.Bounds.Fit(n.region) {
 		return false
 	}
+	n.layers |= object.Layers
+	n.addAggregates(&object)
 
 	// Number of objects < CAPACITY and children is nil => add in objects
-	if len(n.objects) < CAPACITY && n.children == nil {
+	if len(n.objects) < n.tree.getSplitThreshold() && n.children == nil {
 		n.objects = append(n.objects, object)
 		return true
 	}
@@ -33,30 +56,37 @@ func (n *Node) insert(object Object) bool {
 	// Number of objects >= CAPACITY and children is nil => create children,
 	// try to move all objects in children
 	// and try to add in children otherwise add in objects
-	if len(n.objects) >= CAPACITY && n.children == nil {
+	if len(n.objects) >= n.tree.getSplitThreshold() && n.children == nil {
 		n.split()
 
 		objects := n.objects
 		n.objects = []Object{}
 
-		// Move old objects to children
+		// Move old objects to children, they are already counted in the aggregates of n
 		for i := range objects {
-			n.insert(objects[i])
+			n.pushDown(objects[i])
 		}
 	}
 
 	// Children isn't nil => try to add in children otherwise add in objects
 	if n.children != nil {
-		for i := range n.children {
-			if n.children[i].insert(object) {
-				return true
-			}
-		}
+		n.pushDown(object)
+		return true
 	}
 	n.objects = append(n.objects, object)
 	return true
 }
 
+// pushDown inserts object in the first child fitting it, otherwise keeps it in n
+func (n *Node) pushDown(object Object) {
+	for i := range n.children {
+		if n.children[i].insert(object) {
+			return
+		}
+	}
+	n.objects = append(n.objects, object)
+}
+
 func (n *Node) remove(object Object) bool {
 	// Object outside Bounds
 	if !object.Bounds.Intersects(n.region) {
@@ -68,7 +98,8 @@ func (n *Node) remove(object Object) bool {
 		if o.Equal(object) {
 			// https://stackoverflow.com/questions/37334119/how-to-delete-an-element-from-a-slice-in-golang
 			n.objects = append(n.objects[:i], n.objects[i+1:]...)
-			n.merge()
+			n.mergeOnRemove()
+			n.update()
 			return true
 		}
 	}
@@ -77,7 +108,8 @@ func (n *Node) remove(object Object) bool {
 	if n.children != nil {
 		for i := range n.children {
 			if n.children[i].remove(object) {
-				n.merge()
+				n.mergeOnRemove()
+				n.update()
 				return true
 			}
 		}
@@ -85,62 +117,185 @@ func (n *Node) remove(object Object) bool {
 	return false
 }
 
-func (n *Node) getColliding(bounds volume.Box) []Object {
+// appendColliding appends to dst the objects of the subtree intersecting bounds and matching mask
+func (n *Node) appendColliding(dst []Object, bounds volume.Box, mask uint64) []Object {
+	n.metrics().add(countVisits)
+	// No object of these layers in the subtree
+	if !matchLayers(n.layers, mask) {
+		return dst
+	}
 	// If current node region entirely fit inside desired Bounds,
 	// No need to search somewhere else => return all objects
 	if n.region.Fit(bounds) {
-		return n.getAllObjects()
+		return n.appendAllObjects(dst, mask)
 	}
-	var objects []Object
 	// If bounds doesn't intersects with region, no collision here => return empty
 	if !n.region.Intersects(bounds) {
-		return objects
+		return dst
 	}
 	// return objects that intersects with bounds and its children's objects
 	for _, obj := range n.objects {
-		if obj.Bounds.Intersects(bounds) {
-			objects = append(objects, obj)
+		if obj.Bounds.Intersects(bounds) && matchLayers(obj.Layers, mask) {
+			dst = append(dst, obj)
 		}
 	}
 	// No children ? Stop here
 	if n.children == nil {
-		return objects
+		return dst
 	}
 	// Get the colliding children
-	for _, c := range n.children {
-		objects = append(objects, c.getColliding(bounds)...)
+	for i := range n.children {
+		dst = n.children[i].appendColliding(dst, bounds, mask)
 	}
-	return objects
+	return dst
 }
 
-func (n *Node) getAllObjects() []Object {
+// visitColliding calls f on the objects of the subtree intersecting bounds and matching mask, returns false once f did
+func (n *Node) visitColliding(bounds volume.Box, mask uint64, f func(*Object) bool) bool {
+	n.metrics().add(countVisits)
+	if !matchLayers(n.layers, mask) || !n.region.Intersects(bounds) {
+		return true
+	}
+	all := n.region.Fit(bounds)
+	for i := range n.objects {
+		obj := &n.objects[i]
+		if (all || obj.Bounds.Intersects(bounds)) && matchLayers(obj.Layers, mask) && !f(obj) {
+			return false
+		}
+	}
+	if n.children == nil {
+		return true
+	}
+	for i := range n.children {
+		if !n.children[i].visitColliding(bounds, mask, f) {
+			return false
+		}
+	}
+	return true
+}
+
+func (n *Node) getCollidingSphere(center vector3.Vector3, sqRadius float64, mask uint64) []Object {
+	n.metrics().add(countVisits)
 	var objects []Object
-	objects = append(objects, n.objects...)
+	if !matchLayers(n.layers, mask) || sqDistancePointBox(center, n.region) > sqRadius {
+		return objects
+	}
+	for _, obj := range n.objects {
+		if sqDistancePointBox(center, obj.Bounds) <= sqRadius && matchLayers(obj.Layers, mask) {
+			objects = append(objects, obj)
+		}
+	}
 	if n.children == nil {
 		return objects
 	}
 	for _, c := range n.children {
-		objects = append(objects, c.getAllObjects()...)
+		objects = append(objects, c.getCollidingSphere(center, sqRadius, mask)...)
 	}
 	return objects
 }
 
-func (n *Node) getObjects() []Object {
-	return n.objects
+func (n *Node) getAllObjects() []Object {
+	return n.appendAllObjects(nil, AllLayers)
 }
 
-// range is already taken
-func (n *Node) rang(f func(*Object) bool) {
-	for _, o := range n.objects {
-		if !f(&o) {
-			return
+func (n *Node) appendAllObjects(dst []Object, mask uint64) []Object {
+	if mask == AllLayers {
+		dst = append(dst, n.objects...)
+	} else if matchLayers(n.layers, mask) {
+		for _, obj := range n.objects {
+			if matchLayers(obj.Layers, mask) {
+				dst = append(dst, obj)
+			}
 		}
+	} else {
+		return dst
+	}
+	if n.children == nil {
+		return dst
+	}
+	for i := range n.children {
+		dst = n.children[i].appendAllObjects(dst, mask)
+	}
+	return dst
+}
+
+// update recomputes what n summarizes about its subtree, its layers and aggregates,
+// from its objects and its children
+func (n *Node) update() {
+	n.updateLayers()
+	n.updateAggregates()
+}
+
+// updateLayers recomputes the layers of n from its objects and its children
+func (n *Node) updateLayers() {
+	n.layers = 0
+	for i := range n.objects {
+		n.layers |= n.objects[i].Layers
 	}
 	if n.children != nil {
-		for _, c := range n.children {
-			c.rang(f)
+		for i := range n.children {
+			n.layers |= n.children[i].layers
+		}
+	}
+}
+
+// range is already taken, returns false once f did
+func (n *Node) rang(f func(*Object) bool) bool {
+	for i := range n.objects {
+		if !f(&n.objects[i]) {
+			return false
+		}
+	}
+	if n.children != nil {
+		for i := range n.children {
+			if !n.children[i].rang(f) {
+				return false
+			}
 		}
 	}
+	return true
+}
+
+// rangeMutate calls f like rang, the objects whose bounds were changed by f are removed and appended to moved.
+// The nodes are merged after the removals in their subtree, it returns false once f did
+func (n *Node) rangeMutate(f func(*Object) bool, moved []Object) ([]Object, bool) {
+	before := len(moved)
+	ok := true
+	// Filtered in place, the kept objects are written at an index lower or equal to the current one
+	kept := n.objects[:0]
+	for i := range n.objects {
+		obj := &n.objects[i]
+		if ok {
+			min, max := *obj.Bounds.Min, *obj.Bounds.Max
+			ok = f(obj)
+			if !sameVector(min, *obj.Bounds.Min) || !sameVector(max, *obj.Bounds.Max) {
+				moved = append(moved, *obj)
+				continue
+			}
+		}
+		kept = append(kept, *obj)
+	}
+	for i := len(kept); i < len(n.objects); i++ {
+		n.objects[i] = Object{}
+	}
+	n.objects = kept
+	if ok && n.children != nil {
+		for i := range n.children {
+			if moved, ok = n.children[i].rangeMutate(f, moved); !ok {
+				break
+			}
+		}
+	}
+	if len(moved) > before {
+		n.mergeOnRemove()
+	}
+	// f may have changed the layers or the aggregated values
+	n.update()
+	return moved, ok
+}
+
+func sameVector(a, b vector3.Vector3) bool {
+	return a.X == b.X && a.Y == b.Y && a.Z == b.Z
 }
 
 /* Merge all children into this node - the opposite of Split.
@@ -159,7 +314,10 @@ func (n *Node) merge() bool {
 			totalObjects += len(child.objects)
 		}
 	}
-	if totalObjects > CAPACITY {
+	if totalObjects > n.tree.getMergeThreshold() {
+		return false
+	}
+	if n.tree != nil && n.tree.balance21 && !n.balanced21(n.depth) {
 		return false
 	}
 
@@ -174,11 +332,37 @@ func (n *Node) merge() bool {
 		}
 		// Remove the child nodes (and the objects in them - they've been added elsewhere now)
 		n.children = nil
+		n.metrics().add(countMerges)
 		return true
 	}
 	return false
 }
 
+// mergeOnRemove merges n after a removal in its subtree, unless the merges are deferred to Compact
+func (n *Node) mergeOnRemove() {
+	if n.tree == nil || !n.tree.deferredMerge {
+		n.merge()
+	}
+}
+
+// compact pushes the objects of n fitting in a child down, then compacts the children and merges n.
+// The merges are done bottom-up so they can cascade to the parents
+func (n *Node) compact() {
+	if n.children == nil {
+		return
+	}
+	objects := n.objects
+	n.objects = nil
+	for _, obj := range objects {
+		n.pushDown(obj)
+	}
+	for i := range n.children {
+		n.children[i].compact()
+	}
+	n.merge()
+	n.update()
+}
+
 func (n *Node) move(object *Object, newPosition ...float64) bool {
 	// Can't find it
 	if len(newPosition) != 3 || !n.remove(*object) {
@@ -200,19 +384,31 @@ func (n *Node) split() {
 	subBoxes := n.region.Split()
 	n.children = &[8]Node{}
 	for i := range subBoxes {
-		n.children[i] = Node{region: *subBoxes[i]}
+		n.children[i] = Node{region: *subBoxes[i], tree: n.tree, parent: n, depth: n.depth + 1}
+		n.children[i].updateAggregates()
 	}
+	n.metrics().add(countSplits)
 }
 
 /* * * * * * * * * * * * * * * * * Debugging * * * * * * * * * * * * * * * * */
+
+// walk calls f on n and all its descendants in DFS order along with their depth, n being at depth.
+// The children of a node are skipped when f returns false
+func (n *Node) walk(depth int, f func(*Node, int) bool) {
+	if !f(n, depth) || n.children == nil {
+		return
+	}
+	for i := range n.children {
+		n.children[i].walk(depth+1, f)
+	}
+}
+
 func (n *Node) getNodes() []Node {
 	var nodes []Node
-	nodes = append(nodes, *n)
-	if n.children != nil {
-		for _, c := range n.children {
-			nodes = append(nodes, c.getNodes()...)
-		}
-	}
+	n.walk(0, func(c *Node, _ int) bool {
+		nodes = append(nodes, *c)
+		return true
+	})
 	return nodes
 }
 
@@ -221,6 +417,25 @@ func (n *Node) GetRegion() volume.Box {
 	return n.region
 }
 
+// GetObjects returns the objects stored in this node, not in its children.
+// The returned slice must not be modified
+func (n *Node) GetObjects() []Object {
+	return n.objects
+}
+
+// GetChildren returns the eight children of the node, nil if it is a leaf.
+// Child i covers the upper half of x if i&4 != 0, of y if i&2 != 0 and of z if i&1 != 0
+func (n *Node) GetChildren() []*Node {
+	if n.children == nil {
+		return nil
+	}
+	children := make([]*Node, len(n.children))
+	for i := range n.children {
+		children[i] = &n.children[i]
+	}
+	return children
+}
+
 func (n *Node) getHeight() int {
 	if n.children == nil {
 		return 1
@@ -235,11 +450,12 @@ func (n *Node) getHeight() int {
 	return max + 1
 }
 
+// getNumberOfNodes counts n and its descendants, once each
 func (n *Node) getNumberOfNodes() int {
 	if n.children == nil {
 		return 1
 	}
-	sum := len(n.children)
+	sum := 1
 	for _, c := range n.children {
 		nb := c.getNumberOfNodes()
 		sum += nb
@@ -49,11 +49,27 @@ type Object struct {
 	id     uint64
 	Data   interface{}
 	Bounds volume.Box
+	// Layers is a bitmask of the layers the object belongs to, queries with a mask only return
+	// the objects sharing at least one layer with it, e.g. GetCollidingMask. DefaultLayers by default
+	Layers uint64
 }
 
+// Layer masks
+const (
+	// DefaultLayers are the layers of new objects
+	DefaultLayers uint64 = 1
+	// AllLayers is the mask of the queries without filter, it matches every object, even without layers
+	AllLayers uint64 = ^uint64(0)
+)
+
 // NewObject is a Object constructor with bounds for ease of use
 func NewObject(data interface{}, bounds volume.Box) *Object {
-	return &Object{id: newID(), Data: data, Bounds: bounds}
+	return &Object{id: newID(), Data: data, Bounds: bounds, Layers: DefaultLayers}
+}
+
+// matchLayers returns whether layers pass the filter mask
+func matchLayers(layers, mask uint64) bool {
+	return mask == AllLayers || layers&mask != 0
 }
 
 // NewObjectCube returns a new cubic object of given size
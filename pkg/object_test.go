@@ -2,10 +2,13 @@ package octree
 
 import (
     "github.com/louis030195/protometry/api/volume"
+    "sync/atomic"
     "testing"
 )
 
 func TestObject_Equal(t *testing.T) {
+	// Other tests may have created objects before, the ids follow the last one
+	last := atomic.LoadUint64(&idInc)
 	type fields struct {
 		id     uint64
 		Data   interface{}
@@ -23,7 +26,7 @@ func TestObject_Equal(t *testing.T) {
 		// TODO: Add test cases.
 		{
 			fields: fields{
-				id:     1, // Because NewObject increments the id automatically
+				id:     last + 1, // Because NewObject increments the id automatically
 				Data:   nil,
 				Bounds: volume.Box{},
 			},
@@ -32,7 +35,7 @@ func TestObject_Equal(t *testing.T) {
 		},
 		{
 			fields: fields{
-				id:     2, // Because NewObject increments the id automatically
+				id:     last + 2, // Because NewObject increments the id automatically
 				Data:   2728624,
 				Bounds: volume.Box{},
 			},
@@ -50,7 +53,7 @@ func TestObject_Equal(t *testing.T) {
 		},
 		{ // Equality is only checked on id, not data or bounds
 			fields: fields{
-				id:     4,
+				id:     last + 4,
 				Data:   nil,
 				Bounds: *volume.NewBoxOfSize(0, 27.332, 0, 1),
 			},
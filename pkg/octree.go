@@ -2,40 +2,174 @@ package octree
 
 import (
     "fmt"
+    "github.com/louis030195/protometry/api/vector3"
     "github.com/louis030195/protometry/api/volume"
+    "time"
 )
 
 // Octree ...
 type Octree struct {
-	root *Node
+	root    *Node
+	metrics *Metrics
+	// 0 means CAPACITY
+	splitThreshold int
+	mergeThreshold int
+	deferredMerge  bool
+	// balance21 keeps adjacent leaves within one level, see Balance21
+	balance21 bool
+	// version is incremented by every modification, see RebuildAsync
+	version uint64
+	// aggregators maintained in the nodes, see WithAggregator
+	aggregators []Aggregator
+	// mass is the aggregator of ApproximateField, see WithMass
+	mass *MassAggregator
+}
+
+// Option configures an Octree, see NewOctree
+type Option func(*Octree)
+
+// WithSplitThreshold sets the number of objects a leaf holds before splitting, CAPACITY by default
+func WithSplitThreshold(n int) Option {
+	return func(o *Octree) {
+		o.splitThreshold = n
+	}
+}
+
+// WithMergeThreshold sets the number of objects under which the children of a node are merged back into it,
+// the split threshold by default. A lower value, e.g. half the split threshold, prevents an object
+// going back and forth across the limit from splitting and merging the node every time.
+// It can't be greater than the split threshold.
+func WithMergeThreshold(n int) Option {
+	return func(o *Octree) {
+		o.mergeThreshold = n
+	}
+}
+
+// WithDeferredMerge disables the merges on removal, they are done by Compact instead
+func WithDeferredMerge() Option {
+	return func(o *Octree) {
+		o.deferredMerge = true
+	}
+}
+
+// getSplitThreshold returns the number of objects a leaf holds before splitting
+func (o *Octree) getSplitThreshold() int {
+	if o == nil || o.splitThreshold <= 0 {
+		return CAPACITY
+	}
+	return o.splitThreshold
+}
+
+// getMergeThreshold returns the number of objects children must hold at most to be merged
+func (o *Octree) getMergeThreshold() int {
+	split := o.getSplitThreshold()
+	if o == nil || o.mergeThreshold <= 0 || o.mergeThreshold > split {
+		return split
+	}
+	return o.mergeThreshold
 }
 
 // NewOctree is a Octree constructor for ease of use
-func NewOctree(region *volume.Box) *Octree {
-	return &Octree{
-		root: &Node{region: *region},
+func NewOctree(region *volume.Box, opts ...Option) *Octree {
+	o := &Octree{}
+	for _, opt := range opts {
+		opt(o)
 	}
+	o.root = &Node{region: *region, tree: o}
+	o.root.updateAggregates()
+	return o
 }
 
 // Insert a object in the Octree, TODO: bool or object return?
 func (o *Octree) Insert(object Object) bool {
-	return o.root.insert(object)
+	o.version++
+	if !o.metrics.count(countInserts, o.root.insert(object)) {
+		return false
+	}
+	o.balanceAround(object.Bounds)
+	return true
 }
 
 // Move object to a new Bounds, pass a pointer because we want to modify the passed object data
 func (o *Octree) Move(object *Object, newPosition ...float64) bool {
-	return o.root.move(object, newPosition...)
+	o.version++
+	if !o.metrics.count(countMoves, o.root.move(object, newPosition...)) {
+		return false
+	}
+	o.balanceAround(object.Bounds)
+	return true
 }
 
 // Remove object
 func (o *Octree) Remove(object Object) bool {
-	return o.root.remove(object)
+	o.version++
+	return o.metrics.count(countRemoves, o.root.remove(object))
 }
 
 // GetColliding returns an array of objects that intersect with the specified bounds, if any.
 // Otherwise returns an empty array.
 func (o *Octree) GetColliding(bounds volume.Box) []Object {
-	return o.root.getColliding(bounds)
+	return o.AppendColliding(nil, bounds)
+}
+
+// GetCollidingMask is GetColliding restricted to the objects sharing a layer with mask
+func (o *Octree) GetCollidingMask(bounds volume.Box, mask uint64) []Object {
+	return o.AppendCollidingMask(nil, bounds, mask)
+}
+
+// AppendColliding appends the objects that intersect with the specified bounds to dst and returns the extended slice,
+// so the same buffer can be reused across queries, e.g. o.AppendColliding(buf[:0], bounds)
+func (o *Octree) AppendColliding(dst []Object, bounds volume.Box) []Object {
+	return o.AppendCollidingMask(dst, bounds, AllLayers)
+}
+
+// AppendCollidingMask is AppendColliding restricted to the objects sharing a layer with mask,
+// the subtrees without any of these layers are skipped
+func (o *Octree) AppendCollidingMask(dst []Object, bounds volume.Box, mask uint64) []Object {
+	if o.metrics != nil {
+		defer o.metrics.observe(queryColliding, time.Now())
+	}
+	return o.root.appendColliding(dst, bounds, mask)
+}
+
+// VisitColliding calls f sequentially for each object that intersects with the specified bounds, without copying them.
+// If f returns false, the iteration stops. f must not modify the bounds of the objects nor the tree
+func (o *Octree) VisitColliding(bounds volume.Box, f func(*Object) bool) {
+	o.root.visitColliding(bounds, AllLayers, f)
+}
+
+// VisitCollidingMask is VisitColliding restricted to the objects sharing a layer with mask
+func (o *Octree) VisitCollidingMask(bounds volume.Box, mask uint64, f func(*Object) bool) {
+	o.root.visitColliding(bounds, mask, f)
+}
+
+// GetCollidingSphere returns an array of objects that intersect with the sphere, if any.
+// Otherwise returns an empty array.
+func (o *Octree) GetCollidingSphere(center vector3.Vector3, radius float64) []Object {
+	return o.GetCollidingSphereMask(center, radius, AllLayers)
+}
+
+// GetCollidingSphereMask is GetCollidingSphere restricted to the objects sharing a layer with mask
+func (o *Octree) GetCollidingSphereMask(center vector3.Vector3, radius float64, mask uint64) []Object {
+	if o.metrics != nil {
+		defer o.metrics.observe(querySphere, time.Now())
+	}
+	return o.root.getCollidingSphere(center, radius*radius, mask)
+}
+
+// Compact repairs a tree degraded by many updates: the objects are pushed down into the deepest node
+// that fits them, then the nodes whose children hold no more objects than the merge threshold,
+// e.g. empty subtrees, are merged bottom-up. Trees created WithDeferredMerge never merge otherwise
+func (o *Octree) Compact() {
+	o.version++
+	o.root.compact()
+	if o.balance21 {
+		// The merges skipped to keep the balance may be possible once their neighbours are merged
+		for nodes := 0; nodes != o.getNumberOfNodes(); {
+			nodes = o.getNumberOfNodes()
+			o.root.compact()
+		}
+	}
 }
 
 // GetAllObjects return all objects, the returned array is sorted in the DFS order
@@ -44,12 +178,38 @@ func (o *Octree) GetAllObjects() []Object {
 }
 
 // Range based on https://golang.org/src/sync/map.go?s=9749:9805#L296
-// Range calls f sequentially for each object present in the octree.
+// Range calls f sequentially for each object present in the octree, in the DFS order.
 // If f returns false, range stops the iteration.
+// The objects are the ones stored in the tree, f may modify their data but not their bounds, their layers
+// nor anything aggregated, see RangeMutate, and must not modify the tree
 func (o *Octree) Range(f func(*Object) bool) {
 	o.root.rang(f)
 }
 
+// RangeInBox is Range restricted to the objects that intersect with the specified bounds
+func (o *Octree) RangeInBox(bounds volume.Box, f func(*Object) bool) {
+	o.root.visitColliding(bounds, AllLayers, f)
+}
+
+// RangeMutate is Range allowing f to change the bounds, the layers and the aggregated data of the objects,
+// e.g. to move them all at once.
+// The objects whose bounds changed are relocated in the tree once the iteration is over,
+// the ones which no longer fit the region of the tree are dropped and returned.
+// The pointers passed to f are only valid during the call
+func (o *Octree) RangeMutate(f func(*Object) bool) []Object {
+	o.version++
+	moved, _ := o.root.rangeMutate(f, nil)
+	var dropped []Object
+	for _, obj := range moved {
+		if !o.metrics.count(countMoves, o.root.insert(obj)) {
+			dropped = append(dropped, obj)
+			continue
+		}
+		o.balanceAround(obj.Bounds)
+	}
+	return dropped
+}
+
 // Get will try to find a specific object based on an id
 func (o *Octree) Get(id uint64, box volume.Box) *Object {
 	objs := o.GetColliding(box)
@@ -89,7 +249,7 @@ func (o *Octree) getNumberOfObjects() int {
 
 // getUsage ...
 func (o *Octree) getUsage() float64 {
-	return float64(o.getNumberOfObjects()) / float64(o.getNumberOfNodes()*CAPACITY)
+	return float64(o.getNumberOfObjects()) / float64(o.getNumberOfNodes()*o.getSplitThreshold())
 }
 
 func (o *Octree) toString(verbose bool) string {
@@ -61,6 +61,7 @@ func checkOctree(t *testing.T, o Octree, expectedObjects int) {
 	t.Logf("Octree objects: %v", o.getNumberOfObjects())
 	t.Logf("Octree is balanced %v", ensureBalanced(t, *o.root))
 	equals(t, expectedObjects, o.getNumberOfObjects())
+	equals(t, nil, o.Validate())
 	// equals(t, true, o.getUsage() < 1)
 }
 
@@ -139,6 +140,20 @@ func TestNode_GetCollidingThree(t *testing.T) {
 	equals(t, 0, len(o.GetColliding(*volume.NewBoxOfSize(size*2, size*2, size*2, size))))
 }
 
+func TestOctree_GetCollidingSphere(t *testing.T) {
+	o := NewOctree(volume.NewBoxOfSize(0, 0, 0, 100))
+	equals(t, true, o.Insert(*NewObjectCube(0, 3, 0, 0, 2)))
+	equals(t, true, o.Insert(*NewObjectCube(1, 3, 3, 3, 2)))
+	equals(t, true, o.Insert(*NewObjectCube(2, -20, 0, 0, 2)))
+	// The corner of the second cube is in the bounding box of the sphere but not in the sphere
+	colliders := o.GetCollidingSphere(*vector3.NewVector3Zero(), 2.5)
+	equals(t, 1, len(colliders))
+	equals(t, 0, colliders[0].Data)
+	equals(t, 2, len(o.GetCollidingSphere(*vector3.NewVector3Zero(), 3.5)))
+	equals(t, 3, len(o.GetCollidingSphere(*vector3.NewVector3Zero(), 100)))
+	equals(t, 0, len(o.GetCollidingSphere(*vector3.NewVector3(0, -30, 0), 10)))
+}
+
 func TestOctree_Remove(t *testing.T) {
 	o := boilerplateTree(t)
 	myObj := NewObjectCube(27, 2, 2, 3, 2)
@@ -257,13 +272,12 @@ func TestOctree_Move(t *testing.T) {
 	// Try random moves at scale
 	s := 100.
 	o = octreeRandomInsertions(t, s)
-	o.Range(func(object *Object) bool {
+	for _, object := range o.GetAllObjects() {
 		// Move randomly all objects
 		p := vector3.RandomSpherePoint(*vector3.NewVector3Zero(), s/2)
-		equals(t, true, o.Move(object, p.X, p.Y, p.Z))
+		equals(t, true, o.Move(&object, p.X, p.Y, p.Z))
 		checkOctree(t, *o, int(s))
-		return true
-	})
+	}
 }
 
 func TestOctree_MoveIncorrectDims(t *testing.T) {
@@ -357,6 +371,81 @@ func TestOctree_Range(t *testing.T) {
 	equals(t, i, 100)
 	// We want to check if we iterated through DISTINCT objects, no duplicate !
 	equals(t, 0, len(objs))
+
+	// Stops globally, not only in the current node
+	i = 0
+	o.Range(func(object *Object) bool {
+		i++
+		return i < 50
+	})
+	equals(t, 50, i)
+
+	// The objects are the stored ones
+	o.Range(func(object *Object) bool {
+		object.Data = -1
+		return true
+	})
+	for _, obj := range o.GetAllObjects() {
+		equals(t, -1, obj.Data)
+	}
+
+	box := *volume.NewBoxOfSize(0, 0, 0, 100)
+	i = 0
+	o.RangeInBox(box, func(object *Object) bool {
+		equals(t, true, object.Bounds.Intersects(box))
+		i++
+		return true
+	})
+	equals(t, len(o.GetColliding(box)), i)
+}
+
+func TestOctree_RangeMutate(t *testing.T) {
+	s := 100.
+	o := octreeRandomInsertions(t, s)
+	objects := o.GetAllObjects()
+
+	// Move all the objects at once, the last one outside the tree
+	i := 0
+	dropped := o.RangeMutate(func(object *Object) bool {
+		i++
+		if i == int(s) {
+			object.Bounds = *volume.NewBoxOfSize(s*10, 0, 0, 1)
+			return true
+		}
+		p := vector3.RandomSpherePoint(*vector3.NewVector3Zero(), s/2)
+		object.Bounds = *volume.NewBoxOfSize(p.X, p.Y, p.Z, 1)
+		return true
+	})
+	equals(t, 1, len(dropped))
+	equals(t, objects[len(objects)-1].ID(), dropped[0].ID())
+	checkOctree(t, *o, int(s)-1)
+	for _, obj := range o.GetAllObjects() {
+		equals(t, true, obj.Bounds.Fit(*volume.NewBoxOfSize(0, 0, 0, s+2)))
+	}
+
+	// Stopped early, in place changes are detected too
+	var shifted []Object
+	dropped = o.RangeMutate(func(object *Object) bool {
+		object.Bounds.Min.X -= s / 4
+		object.Bounds.Max.X -= s / 4
+		shifted = append(shifted, *object)
+		return len(shifted) < 10
+	})
+	equals(t, 0, len(dropped))
+	equals(t, 10, len(shifted))
+	checkOctree(t, *o, int(s)-1)
+	for _, obj := range shifted {
+		equals(t, true, o.Get(obj.ID(), obj.Bounds) != nil)
+	}
+
+	// Unchanged bounds, nothing moves
+	root := *o.root
+	o.RangeMutate(func(object *Object) bool {
+		object.Data = 1
+		return true
+	})
+	equals(t, root.children, o.root.children)
+	checkOctree(t, *o, int(s)-1)
 }
 
 func octreeRandomInsertions(t testing.TB, treeSize float64) *Octree {
@@ -368,6 +457,17 @@ func octreeRandomInsertions(t testing.TB, treeSize float64) *Octree {
 	return o
 }
 
+// seededInsertions is octreeRandomInsertions with the positions drawn from a seeded source, uniformly in a cube
+func seededInsertions(t testing.TB, treeSize float64, seed int64) *Octree {
+	o := NewOctree(volume.NewBoxOfSize(0, 0, 0, treeSize*2))
+	r := rand.New(rand.NewSource(seed))
+	coordinate := func() float64 { return (r.Float64()*2 - 1) * (treeSize - 1) }
+	for i := 0.; i < treeSize; i++ {
+		equals(t, true, o.Insert(*NewObjectCube(0, coordinate(), coordinate(), coordinate(), 1)))
+	}
+	return o
+}
+
 func TestOctree_GetNodes(t *testing.T) {
 	ts := 6.
 	o := octreeRandomInsertions(t, ts)
@@ -542,3 +642,210 @@ func BenchmarkOctree_Range(b *testing.B) {
 		return true
 	})
 }
+
+func TestOctree_MergeThreshold(t *testing.T) {
+	// An object going back and forth across a full node,
+	// with the same thresholds the node is split and merged back every time
+	obj := NewObjectCube(0, -2, -2, -2, 1)
+	m := NewMetrics()
+	o := NewOctree(volume.NewBoxOfSize(0, 0, 0, 8), WithMetrics(m))
+	for i := 0; i < CAPACITY; i++ {
+		equals(t, true, o.Insert(*NewObjectCube(i, float64(i%2)*4-2, 2, 2, 1)))
+	}
+	for i := 0; i < 10; i++ {
+		equals(t, true, o.Insert(*obj))
+		equals(t, true, o.Remove(*obj))
+	}
+	equals(t, uint64(10), m.counters[countSplits])
+	equals(t, uint64(10), m.counters[countMerges])
+
+	// Merging at half the capacity, the node stays split
+	m = NewMetrics()
+	o = NewOctree(volume.NewBoxOfSize(0, 0, 0, 8), WithMetrics(m), WithMergeThreshold(CAPACITY/2))
+	for i := 0; i < CAPACITY; i++ {
+		equals(t, true, o.Insert(*NewObjectCube(i, float64(i%2)*4-2, 2, 2, 1)))
+	}
+	for i := 0; i < 10; i++ {
+		equals(t, true, o.Insert(*obj))
+		equals(t, true, o.Remove(*obj))
+		equals(t, nil, o.Validate())
+	}
+	equals(t, uint64(1), m.counters[countSplits])
+	equals(t, uint64(0), m.counters[countMerges])
+	for _, obj := range o.GetAllObjects()[CAPACITY/2:] {
+		equals(t, true, o.Remove(obj))
+	}
+	equals(t, uint64(1), m.counters[countMerges])
+	equals(t, 1, len(o.GetNodes()))
+
+	// Thresholds are clamped
+	o = NewOctree(volume.NewBoxOfSize(0, 0, 0, 8), WithSplitThreshold(3), WithMergeThreshold(10))
+	equals(t, 3, o.getMergeThreshold())
+}
+
+func TestOctree_DeferredMerge(t *testing.T) {
+	o := NewOctree(volume.NewBoxOfSize(0, 0, 0, 8), WithDeferredMerge())
+	objects := make([]*Object, CAPACITY+1)
+	for i := range objects {
+		objects[i] = NewObjectCube(i, 2, 2, 2, 1)
+		equals(t, true, o.Insert(*objects[i]))
+	}
+	equals(t, 17, len(o.GetNodes()))
+	for _, obj := range objects {
+		equals(t, true, o.Remove(*obj))
+	}
+	equals(t, 17, len(o.GetNodes()))
+	equals(t, nil, o.Validate())
+
+	// Both levels are merged at once
+	o.Compact()
+	equals(t, 1, len(o.GetNodes()))
+	equals(t, nil, o.Validate())
+}
+
+func TestOctree_VisitColliding(t *testing.T) {
+	o := seededInsertions(t, 1000, 1)
+	bounds := *volume.NewBoxOfSize(0, 0, 0, 500)
+	expected := o.GetColliding(bounds)
+	equals(t, true, len(expected) > 10)
+
+	var visited []Object
+	o.VisitColliding(bounds, func(obj *Object) bool {
+		visited = append(visited, *obj)
+		return true
+	})
+	equals(t, expected, visited)
+
+	// Stops globally
+	visited = visited[:0]
+	o.VisitColliding(bounds, func(obj *Object) bool {
+		visited = append(visited, *obj)
+		return len(visited) < 10
+	})
+	equals(t, expected[:10], visited)
+
+	// The objects are the stored ones, not copies
+	var first *Object
+	o.VisitColliding(bounds, func(obj *Object) bool {
+		first = obj
+		return false
+	})
+	first.Data = "visited"
+	equals(t, "visited", o.GetColliding(bounds)[0].Data)
+}
+
+func TestOctree_AppendColliding(t *testing.T) {
+	o := seededInsertions(t, 1000, 1)
+	bounds := *volume.NewBoxOfSize(0, 0, 0, 500)
+	expected := o.GetColliding(bounds)
+	buf := make([]Object, 1, len(expected)+1)
+	equals(t, expected, o.AppendColliding(buf, bounds)[1:])
+
+	buf = o.AppendColliding(buf[:0], bounds)
+	allocs := testing.AllocsPerRun(10, func() {
+		buf = o.AppendColliding(buf[:0], bounds)
+	})
+	equals(t, 0., allocs)
+	equals(t, expected, buf)
+	equals(t, 0, len(o.AppendColliding(buf[:0], *volume.NewBoxOfSize(10000, 0, 0, 1))))
+}
+
+func BenchmarkOctree_GetColliding(b *testing.B) {
+	o := octreeRandomInsertions(b, 10000)
+	bounds := *volume.NewBoxOfSize(0, 0, 0, 5000)
+	b.Run("GetColliding", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			o.GetColliding(bounds)
+		}
+	})
+	b.Run("AppendColliding", func(b *testing.B) {
+		b.ReportAllocs()
+		var buf []Object
+		for i := 0; i < b.N; i++ {
+			buf = o.AppendColliding(buf[:0], bounds)
+		}
+	})
+	b.Run("VisitColliding", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			o.VisitColliding(bounds, func(*Object) bool { return true })
+		}
+	})
+	b.Run("IterColliding", func(b *testing.B) {
+		b.ReportAllocs()
+		it := o.IterColliding(bounds)
+		for i := 0; i < b.N; i++ {
+			for it.Reset(o, bounds); it.Next(); {
+			}
+		}
+	})
+}
+
+func TestOctree_Layers(t *testing.T) {
+	const friends, enemies = 1, 2
+	m := NewMetrics()
+	o := NewOctree(volume.NewBoxOfSize(0, 0, 0, 8), WithMetrics(m))
+	// Enough friends to split the root and its first child
+	for i := 0; i < CAPACITY+1; i++ {
+		equals(t, true, o.Insert(*NewObjectCube(i, float64(i%2)*2-3, -3, -3, 1)))
+	}
+	enemy := NewObjectCube("enemy", 2, 2, 2, 1)
+	enemy.Layers = enemies
+	equals(t, true, o.Insert(*enemy))
+	equals(t, DefaultLayers, o.GetAllObjects()[0].Layers)
+	equals(t, uint64(friends|enemies), o.root.layers)
+	checkOctree(t, *o, CAPACITY+2)
+
+	visits := func(query func()) uint64 {
+		before := m.counters[countVisits]
+		query()
+		return m.counters[countVisits] - before
+	}
+	bounds := *volume.NewBoxOfSize(0, 0, 0, 7)
+	equals(t, uint64(17), visits(func() { equals(t, CAPACITY+2, len(o.GetColliding(bounds))) }))
+	equals(t, uint64(CAPACITY+1), uint64(len(o.GetCollidingMask(bounds, friends))))
+	// The subtree of the friends is skipped
+	var found []Object
+	equals(t, uint64(9), visits(func() { found = o.GetCollidingMask(bounds, enemies) }))
+	equals(t, 1, len(found))
+	equals(t, enemy.ID(), found[0].ID())
+	equals(t, uint64(1), visits(func() { equals(t, 0, len(o.GetCollidingMask(bounds, 4))) }))
+	equals(t, CAPACITY+2, len(o.GetCollidingMask(bounds, friends|enemies)))
+
+	origin := *vector3.NewVector3(-3, -3, -3)
+	equals(t, enemy.ID(), o.NearestMask(origin, 1, enemies)[0].ID())
+	equals(t, 0, len(o.GetCollidingSphereMask(origin, 2, enemies)))
+	equals(t, 1, len(o.GetCollidingSphereMask(origin, 10, enemies)))
+	hits := o.RaycastMask(origin, *vector3.NewVector3(1, 1, 1), 0, enemies)
+	equals(t, 1, len(hits))
+	equals(t, enemy.ID(), hits[0].Object.ID())
+	n := 0
+	o.VisitCollidingMask(bounds, friends, func(obj *Object) bool {
+		n++
+		return true
+	})
+	equals(t, CAPACITY+1, n)
+	it := o.IterCollidingMask(bounds, enemies)
+	equals(t, true, it.Next())
+	equals(t, enemy.ID(), it.Object().ID())
+	equals(t, false, it.Next())
+	// The mask is kept
+	it.Reset(o, bounds)
+	equals(t, true, it.Next())
+	equals(t, enemy.ID(), it.Object().ID())
+
+	// The nodes forget the layers once their objects are removed
+	equals(t, true, o.Remove(*enemy))
+	equals(t, uint64(friends), o.root.layers)
+	equals(t, uint64(1), visits(func() { equals(t, 0, len(o.GetCollidingMask(bounds, enemies))) }))
+
+	// Objects may change layers in RangeMutate
+	o.RangeMutate(func(obj *Object) bool {
+		obj.Layers = enemies
+		return true
+	})
+	equals(t, uint64(enemies), o.root.layers)
+	equals(t, 0, len(o.GetCollidingMask(bounds, friends)))
+	checkOctree(t, *o, CAPACITY+1)
+}
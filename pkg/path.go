@@ -0,0 +1,228 @@
+package octree
+
+import (
+	"container/heap"
+	"math"
+
+	"github.com/louis030195/protometry/api/vector3"
+	"github.com/louis030195/protometry/api/volume"
+)
+
+// minPathDepth is the depth the free space can be subdivided to when the tree is shallower,
+// small agents would otherwise subdivide it without end around every object
+const minPathDepth = 8
+
+// FindPath returns waypoints from start to goal for an agent of radius agentRadius not colliding with the objects
+// of the tree, nil if there is none. The free space is the leaves of a second octree over the region of the tree,
+// subdivided on demand where a cell grown by agentRadius collides an object, down to cells of agentRadius
+// or as deep as the deepest leaf of the tree, minPathDepth at least.
+// The path is found by A* over the free cells connected through their faces, entering them by the middle
+// of the faces they share, then smoothed by skipping the waypoints in line of sight of the previous one,
+// the agent being swept as its bounding cube
+func (o *Octree) FindPath(start, goal vector3.Vector3, agentRadius float64) []vector3.Vector3 {
+	region := o.root.region
+	size := region.GetSize()
+	depth := minPathDepth
+	o.root.walk(0, func(n *Node, d int) bool {
+		if d > depth {
+			depth = d
+		}
+		return true
+	})
+	p := &pathFinder{
+		tree:       o,
+		space:      NewOctree(&volume.Box{Min: region.Min.Clone(), Max: region.Max.Clone()}, WithDeferredMerge()),
+		radius:     agentRadius,
+		resolution: math.Max(agentRadius, size.X/math.Pow(2, float64(depth))),
+		free:       map[*Node]bool{},
+	}
+	if !region.Contains(start) || !region.Contains(goal) || !p.clearAt(start) || !p.clearAt(goal) {
+		return nil
+	}
+	from, to := p.cellAt(start), p.cellAt(goal)
+	if from == to {
+		// Straight in a cell, there is no face to go through
+		if p.clear(start, goal) {
+			return []vector3.Vector3{start, goal}
+		}
+		return nil
+	}
+	// entries are the points where the path enters the cells, its cost is the length of the path to them
+	entries := map[*Node]vector3.Vector3{from: start}
+	cameFrom := map[*Node]*Node{from: nil}
+	cost := map[*Node]float64{from: 0}
+	q := &pathQueue{{node: from, estimate: start.Distance(goal)}}
+	found := false
+	for q.Len() > 0 {
+		item := heap.Pop(q).(pathItem)
+		n := item.node
+		if n == to {
+			found = true
+			break
+		}
+		if item.cost > cost[n] {
+			// Reached again by a shorter path since pushed
+			continue
+		}
+		for _, direction := range Directions[:6] {
+			for _, neighbor := range p.neighbors(n, direction) {
+				face := sharedFace(n, neighbor)
+				c := cost[n] + entries[n].Distance(face)
+				if previous, ok := cost[neighbor]; ok && previous <= c {
+					continue
+				}
+				// The agent goes anywhere in the free cells, in the cells at the resolution which aren't,
+				// start and goal among others, it must go straight from the entry to the exit
+				if !p.isFree(n) && !p.clear(entries[n], face) || neighbor == to && !p.isFree(to) && !p.clear(face, goal) {
+					continue
+				}
+				entries[neighbor] = face
+				cost[neighbor] = c
+				cameFrom[neighbor] = n
+				heap.Push(q, pathItem{node: neighbor, cost: c, estimate: c + face.Distance(goal)})
+			}
+		}
+	}
+	if !found {
+		return nil
+	}
+	path := []vector3.Vector3{goal}
+	for n := to; n != nil; n = cameFrom[n] {
+		path = append(path, entries[n])
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return p.smooth(path)
+}
+
+// pathFinder holds the state of FindPath
+type pathFinder struct {
+	tree *Octree
+	// space is the free space octree, its leaves are either free or at the resolution
+	space      *Octree
+	radius     float64
+	resolution float64
+	// free caches whether the agent can be anywhere in the cells, only the cells which aren't are split
+	free map[*Node]bool
+}
+
+// isFree returns whether the agent can be anywhere in the cell n
+func (p *pathFinder) isFree(n *Node) bool {
+	if free, ok := p.free[n]; ok {
+		return free
+	}
+	grow := *vector3.NewVector3(p.radius, p.radius, p.radius)
+	min, max := n.region.Min.Minus(grow), n.region.Max.Plus(grow)
+	free := true
+	p.tree.VisitColliding(volume.Box{Min: &min, Max: &max}, func(*Object) bool {
+		free = false
+		return false
+	})
+	p.free[n] = free
+	return free
+}
+
+// divisible returns whether the cell n is larger than the resolution
+func (p *pathFinder) divisible(n *Node) bool {
+	size := n.region.GetSize()
+	return size.X/2 >= p.resolution
+}
+
+// cellAt returns the cell containing point, subdividing the space until it is free or at the resolution
+func (p *pathFinder) cellAt(point vector3.Vector3) *Node {
+	n := p.space.LeafAt(point)
+	for !p.isFree(n) && p.divisible(n) {
+		n.split()
+		n = p.space.LeafAt(point)
+	}
+	return n
+}
+
+// neighbors returns the cells adjacent to n in direction worth visiting: the free ones, and the others at
+// the resolution as start and goal may be in one. The larger cells which aren't free are subdivided
+func (p *pathFinder) neighbors(n *Node, direction Direction) []*Node {
+	d := [3]int{direction.X, direction.Y, direction.Z}
+	min, max := axes(n)
+	var neighbors []*Node
+	queue := p.space.Neighbors(n, direction)
+	for len(queue) > 0 {
+		c := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		if p.isFree(c) || !p.divisible(c) {
+			neighbors = append(neighbors, c)
+			continue
+		}
+		c.split()
+		for i := range c.children {
+			child := &c.children[i]
+			if covers(child, d, min, max) && adjacent(child, d, min, max) {
+				queue = append(queue, child)
+			}
+		}
+	}
+	return neighbors
+}
+
+// clearAt returns whether the agent at point collides no object
+func (p *pathFinder) clearAt(point vector3.Vector3) bool {
+	return len(p.tree.GetColliding(*volume.NewBoxOfSize(point.X, point.Y, point.Z, 2*p.radius))) == 0
+}
+
+// clear returns whether the agent can go straight from a to b, its bounding cube swept along the segment
+func (p *pathFinder) clear(a, b vector3.Vector3) bool {
+	return len(p.tree.Sweep(*volume.NewBoxOfSize(a.X, a.Y, a.Z, 2*p.radius), b.Minus(a))) == 0
+}
+
+// smooth removes the waypoints of path the agent can skip, going straight to the farthest one in line of sight
+func (p *pathFinder) smooth(path []vector3.Vector3) []vector3.Vector3 {
+	smoothed := []vector3.Vector3{path[0]}
+	for i := 0; i < len(path)-1; {
+		next := i + 1
+		for j := len(path) - 1; j > next; j-- {
+			if p.clear(path[i], path[j]) {
+				next = j
+				break
+			}
+		}
+		smoothed = append(smoothed, path[next])
+		i = next
+	}
+	return smoothed
+}
+
+// sharedFace returns the middle of the part of the face shared by the adjacent cells a and b
+func sharedFace(a, b *Node) vector3.Vector3 {
+	aMin, aMax := axes(a)
+	bMin, bMax := axes(b)
+	var middle [3]float64
+	for i := range middle {
+		middle[i] = (math.Max(aMin[i], bMin[i]) + math.Min(aMax[i], bMax[i])) / 2
+	}
+	return *vector3.NewVector3(middle[0], middle[1], middle[2])
+}
+
+// pathItem is a cell waiting to be visited, estimate being the cost to reach it plus the distance to the goal
+type pathItem struct {
+	node     *Node
+	cost     float64
+	estimate float64
+}
+
+// pathQueue is a min-heap of pathItem, implements heap.Interface
+type pathQueue []pathItem
+
+func (q pathQueue) Len() int { return len(q) }
+
+func (q pathQueue) Less(i, j int) bool { return q[i].estimate < q[j].estimate }
+
+func (q pathQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *pathQueue) Push(x interface{}) { *q = append(*q, x.(pathItem)) }
+
+func (q *pathQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,98 @@
+package octree
+
+import (
+	"math"
+	"testing"
+
+	"github.com/louis030195/protometry/api/vector3"
+	"github.com/louis030195/protometry/api/volume"
+)
+
+// wall returns a tree split by a wall at x from 0 to 1, with a hole of 3 by 3 around y = z = 1.5 unless closed
+func wall(t *testing.T, closed bool) *Octree {
+	o := NewOctree(volume.NewBoxOfSize(0, 0, 0, 8), WithSplitThreshold(1))
+	id := 0
+	for y := -3.5; y < 4; y++ {
+		for z := -3.5; z < 4; z++ {
+			if !closed && math.Abs(y-1.5) <= 1 && math.Abs(z-1.5) <= 1 {
+				continue
+			}
+			equals(t, true, o.Insert(*NewObjectCube(id, 0.5, y, z, 1)))
+			id++
+		}
+	}
+	return o
+}
+
+func TestOctree_FindPath(t *testing.T) {
+	o := wall(t, false)
+	start, goal := *vector3.NewVector3(-3, -3, -3), *vector3.NewVector3(3, -3, -3)
+	path := o.FindPath(start, goal, 0.1)
+	equals(t, true, len(path) > 2)
+	equals(t, start, path[0])
+	equals(t, goal, path[len(path)-1])
+	length := 0.
+	for i := 1; i < len(path); i++ {
+		a, b := path[i-1], path[i]
+		equals(t, 0, len(o.Sweep(*volume.NewBoxOfSize(a.X, a.Y, a.Z, 0.2), b.Minus(a))))
+		length += a.Distance(b)
+	}
+	// Through the hole, not much longer than the straight line to it and from it
+	hole := *vector3.NewVector3(0.5, 1.5, 1.5)
+	equals(t, true, length < 1.2*(start.Distance(hole)+hole.Distance(goal)))
+
+	// Smoothed to a straight line on the same side of the wall
+	equals(t, []vector3.Vector3{start, *vector3.NewVector3(-3, 3, 3)}, o.FindPath(start, *vector3.NewVector3(-3, 3, 3), 0.1))
+
+	// In the same leaf
+	end := *vector3.NewVector3(-2, -2, -2)
+	equals(t, []vector3.Vector3{start, end}, o.FindPath(start, end, 0.1))
+
+	// Too large for the hole
+	equals(t, 0, len(o.FindPath(start, goal, 1.1)))
+	// In the wall
+	equals(t, 0, len(o.FindPath(*vector3.NewVector3(0.5, -3, -3), goal, 0.1)))
+	// Outside the tree
+	equals(t, 0, len(o.FindPath(*vector3.NewVector3(10, 0, 0), goal, 0.1)))
+
+	o = wall(t, true)
+	equals(t, 0, len(o.FindPath(start, goal, 0.1)))
+
+	// A single leaf holding a wall, the space around it is free
+	o = NewOctree(volume.NewBoxOfSize(0, 0, 0, 100))
+	equals(t, true, o.Insert(*NewObject(0, *volume.NewBoxMinMax(-1, -5, -5, 1, 5, 5))))
+	equals(t, 1, o.getNumberOfNodes())
+	for _, c := range []struct{ start, goal vector3.Vector3 }{
+		{*vector3.NewVector3(-20, 0, 0), *vector3.NewVector3(20, 0, 0)},
+		// Too close to the wall for the cells around them to be free
+		{*vector3.NewVector3(-1.6, 0, 0), *vector3.NewVector3(1.6, 0, 0)},
+	} {
+		path := o.FindPath(c.start, c.goal, 0.5)
+		equals(t, true, len(path) > 2)
+		equals(t, c.start, path[0])
+		equals(t, c.goal, path[len(path)-1])
+		for i := 1; i < len(path); i++ {
+			a, b := path[i-1], path[i]
+			equals(t, 0, len(o.Sweep(*volume.NewBoxOfSize(a.X, a.Y, a.Z, 1), b.Minus(a))))
+		}
+	}
+
+	// A point agent through a grid of cubes, the space doesn't need to be subdivided much around them
+	o = NewOctree(volume.NewBoxOfSize(0, 0, 0, 100))
+	id := 0
+	for x := -20; x <= 20; x++ {
+		for y := -20; y <= 20; y++ {
+			equals(t, true, o.Insert(*NewObjectCube(id, float64(2*x), float64(2*y), 0, 1)))
+			id++
+		}
+	}
+	start, goal = *vector3.NewVector3(0, 0, -30), *vector3.NewVector3(1, 1, 30)
+	path = o.FindPath(start, goal, 0)
+	equals(t, true, len(path) > 2)
+	equals(t, start, path[0])
+	equals(t, goal, path[len(path)-1])
+	for i := 1; i < len(path); i++ {
+		a, b := path[i-1], path[i]
+		equals(t, 0, len(o.Sweep(*volume.NewBoxOfSize(a.X, a.Y, a.Z, 0), b.Minus(a))))
+	}
+}
@@ -0,0 +1,297 @@
+package pointcloud
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// pcdField is a column of a PCD file
+type pcdField struct {
+	name  string
+	size  int
+	typ   byte // I, U or F
+	count int
+}
+
+type pcdReader struct {
+	r         *bufio.Reader
+	binary    bool
+	fields    []pcdField
+	names     []string
+	remaining int
+	record    []byte
+	line      int
+}
+
+// NewPCDReader reads the header of a PCL PCD file, ascii or binary.
+// The packed rgb and rgba fields are split into red, green, blue and alpha attributes,
+// fields with a count greater than 1 become name_0, name_1... Compressed data isn't supported.
+func NewPCDReader(r io.Reader) (Reader, error) {
+	pr := &pcdReader{r: bufio.NewReader(r)}
+	var sizes, types, counts []string
+	points, width, height := -1, 0, 0
+	for {
+		line, err := pr.r.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("invalid PCD header: %v", unexpectedEOF(err))
+		}
+		pr.line++
+		f := strings.Fields(line)
+		if len(f) == 0 || strings.HasPrefix(f[0], "#") {
+			continue
+		}
+		switch strings.ToUpper(f[0]) {
+		case "FIELDS":
+			for _, name := range f[1:] {
+				pr.fields = append(pr.fields, pcdField{name: name, count: 1})
+			}
+		case "SIZE":
+			sizes = f[1:]
+		case "TYPE":
+			types = f[1:]
+		case "COUNT":
+			counts = f[1:]
+		case "WIDTH":
+			width, err = pcdInt(f)
+		case "HEIGHT":
+			height, err = pcdInt(f)
+		case "POINTS":
+			points, err = pcdInt(f)
+		case "DATA":
+			if len(f) != 2 || (f[1] != "ascii" && f[1] != "binary") {
+				return nil, fmt.Errorf("unsupported PCD data %q, only ascii and binary are supported", strings.Join(f[1:], " "))
+			}
+			pr.binary = f[1] == "binary"
+		}
+		if err != nil {
+			return nil, fmt.Errorf("PCD header line %v: %v", pr.line, err)
+		}
+		if strings.ToUpper(f[0]) == "DATA" {
+			break
+		}
+	}
+	if points < 0 {
+		points = width * height
+	}
+	pr.remaining = points
+
+	if len(sizes) != len(pr.fields) || len(types) != len(pr.fields) || (counts != nil && len(counts) != len(pr.fields)) {
+		return nil, fmt.Errorf("PCD FIELDS, SIZE, TYPE and COUNT lengths don't match")
+	}
+	xyz := 0
+	recordSize := 0
+	for i := range pr.fields {
+		f := &pr.fields[i]
+		size, err := strconv.Atoi(sizes[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid PCD SIZE: %v", err)
+		}
+		f.size = size
+		if len(types[i]) != 1 || !strings.Contains("IUF", types[i]) {
+			return nil, fmt.Errorf("invalid PCD TYPE %v", types[i])
+		}
+		f.typ = types[i][0]
+		if counts != nil {
+			if f.count, err = strconv.Atoi(counts[i]); err != nil {
+				return nil, fmt.Errorf("invalid PCD COUNT: %v", err)
+			}
+		}
+		recordSize += f.size * f.count
+
+		switch {
+		case f.name == "x" || f.name == "y" || f.name == "z":
+			xyz++
+		case f.name == "_":
+			// Padding
+		case (f.name == "rgb" || f.name == "rgba") && f.count == 1:
+			pr.names = append(pr.names, "red", "green", "blue")
+			if f.name == "rgba" {
+				pr.names = append(pr.names, "alpha")
+			}
+		case f.count == 1:
+			pr.names = append(pr.names, f.name)
+		default:
+			for j := 0; j < f.count; j++ {
+				pr.names = append(pr.names, fmt.Sprintf("%v_%v", f.name, j))
+			}
+		}
+	}
+	if xyz != 3 {
+		return nil, fmt.Errorf("PCD file must have x, y and z fields")
+	}
+	pr.record = make([]byte, recordSize)
+	return pr, nil
+}
+
+// pcdInt parses the value of a header line holding a single integer
+func pcdInt(f []string) (int, error) {
+	if len(f) != 2 {
+		return 0, fmt.Errorf("expected a single value for %v, got %v", f[0], len(f)-1)
+	}
+	return strconv.Atoi(f[1])
+}
+
+func (pr *pcdReader) Fields() []string {
+	return pr.names
+}
+
+func (pr *pcdReader) Read() (Point, error) {
+	if pr.remaining == 0 {
+		return Point{}, io.EOF
+	}
+	pr.remaining--
+
+	var values []float64
+	if pr.binary {
+		if _, err := io.ReadFull(pr.r, pr.record); err != nil {
+			return Point{}, unexpectedEOF(err)
+		}
+		values = pr.decodeBinary()
+	} else {
+		line, err := pr.r.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return Point{}, unexpectedEOF(err)
+		}
+		pr.line++
+		f := strings.Fields(line)
+		for _, field := range pr.fields {
+			// 4 bytes floats are parsed as such so the packed colors keep their bits
+			bitSize := 64
+			if field.typ == 'F' && field.size == 4 {
+				bitSize = 32
+			}
+			for j := 0; j < field.count && len(values) < len(f); j++ {
+				v, err := strconv.ParseFloat(f[len(values)], bitSize)
+				if err != nil {
+					return Point{}, fmt.Errorf("PCD line %v: %v", pr.line, err)
+				}
+				values = append(values, v)
+			}
+		}
+	}
+
+	p := Point{Attributes: make(Attributes, len(pr.names))}
+	i := 0
+	for _, f := range pr.fields {
+		if i+f.count > len(values) {
+			return Point{}, fmt.Errorf("PCD line %v: missing values", pr.line)
+		}
+		v := values[i]
+		switch {
+		case f.name == "x":
+			p.Position.X = v
+		case f.name == "y":
+			p.Position.Y = v
+		case f.name == "z":
+			p.Position.Z = v
+		case f.name == "_":
+		case (f.name == "rgb" || f.name == "rgba") && f.count == 1:
+			// PCL packs the color in the bits of a 4 bytes field, usually a float
+			bits := uint32(v)
+			if f.typ == 'F' {
+				bits = math.Float32bits(float32(v))
+			}
+			p.Attributes["red"] = float64(bits >> 16 & 0xff)
+			p.Attributes["green"] = float64(bits >> 8 & 0xff)
+			p.Attributes["blue"] = float64(bits & 0xff)
+			if f.name == "rgba" {
+				p.Attributes["alpha"] = float64(bits >> 24)
+			}
+		case f.count == 1:
+			p.Attributes[f.name] = v
+		default:
+			for j := 0; j < f.count; j++ {
+				p.Attributes[fmt.Sprintf("%v_%v", f.name, j)] = values[i+j]
+			}
+		}
+		i += f.count
+	}
+	return p, nil
+}
+
+// decodeBinary converts a little endian record to one value per field element
+func (pr *pcdReader) decodeBinary() []float64 {
+	var values []float64
+	b := pr.record
+	for _, f := range pr.fields {
+		for j := 0; j < f.count; j++ {
+			values = append(values, decodePCDValue(b[:f.size], f.typ))
+			b = b[f.size:]
+		}
+	}
+	return values
+}
+
+func decodePCDValue(b []byte, typ byte) float64 {
+	le := binary.LittleEndian
+	switch {
+	case typ == 'F' && len(b) == 4:
+		return float64(math.Float32frombits(le.Uint32(b)))
+	case typ == 'F' && len(b) == 8:
+		return math.Float64frombits(le.Uint64(b))
+	case typ == 'I' && len(b) == 1:
+		return float64(int8(b[0]))
+	case typ == 'I' && len(b) == 2:
+		return float64(int16(le.Uint16(b)))
+	case typ == 'I' && len(b) == 4:
+		return float64(int32(le.Uint32(b)))
+	case typ == 'I' && len(b) == 8:
+		return float64(int64(le.Uint64(b)))
+	case len(b) == 1:
+		return float64(b[0])
+	case len(b) == 2:
+		return float64(le.Uint16(b))
+	case len(b) == 4:
+		return float64(le.Uint32(b))
+	case len(b) == 8:
+		return float64(le.Uint64(b))
+	}
+	return 0
+}
+
+// WritePCD writes the points as an ascii PCD v0.7 file. When the points have a color
+// it is packed into a rgb float field as PCL expects. Missing attributes are written as 0.
+func WritePCD(w io.Writer, points []Point) error {
+	bw := bufio.NewWriter(w)
+	names := fields(points)
+	rgb := false
+	var others []string
+	for _, name := range names {
+		if name == "red" || name == "green" || name == "blue" {
+			rgb = true
+			continue
+		}
+		others = append(others, name)
+	}
+
+	columns := append([]string{"x", "y", "z"}, others...)
+	if rgb {
+		columns = append(columns, "rgb")
+	}
+	sizes := strings.TrimSpace(strings.Repeat("8 ", len(columns)))
+	types := strings.TrimSpace(strings.Repeat("F ", len(columns)))
+	counts := strings.TrimSpace(strings.Repeat("1 ", len(columns)))
+	if rgb {
+		// rgb must stay a 4 bytes float to be unpacked by PCL
+		sizes = sizes[:len(sizes)-1] + "4"
+	}
+	fmt.Fprintf(bw, "# .PCD v0.7 - Point Cloud Data file format\nVERSION 0.7\n")
+	fmt.Fprintf(bw, "FIELDS %v\nSIZE %v\nTYPE %v\nCOUNT %v\n", strings.Join(columns, " "), sizes, types, counts)
+	fmt.Fprintf(bw, "WIDTH %v\nHEIGHT 1\nVIEWPOINT 0 0 0 1 0 0 0\nPOINTS %v\nDATA ascii\n", len(points), len(points))
+	for _, p := range points {
+		writeRow(bw, p, others)
+		if rgb {
+			r, g, b, _ := p.Attributes.Color()
+			packed := math.Float32frombits(uint32(r)<<16 | uint32(g)<<8 | uint32(b))
+			bw.WriteByte(' ')
+			bw.WriteString(strconv.FormatFloat(float64(packed), 'g', -1, 32))
+		}
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
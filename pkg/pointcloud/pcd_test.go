@@ -0,0 +1,75 @@
+package pointcloud
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestPCD(t *testing.T) {
+	var b bytes.Buffer
+	equals(t, nil, WritePCD(&b, testPoints()))
+	equals(t, true, strings.Contains(b.String(), "FIELDS x y z intensity rgb\nSIZE 8 8 8 8 4\n"))
+	r, err := NewPCDReader(&b)
+	equals(t, []string{"intensity", "red", "green", "blue"}, r.Fields())
+	equals(t, testPoints(), readAll(t, r, err))
+
+	r, err = NewPCDReader(strings.NewReader(`# .PCD v0.7
+VERSION 0.7
+FIELDS x y z normal _
+SIZE 4 4 4 4 1
+TYPE F F F F U
+COUNT 1 1 1 3 1
+WIDTH 1
+HEIGHT 1
+POINTS 1
+DATA ascii
+1 2 3 0 0 1 0
+`))
+	points := readAll(t, r, err)
+	equals(t, 1, len(points))
+	equals(t, Attributes{"normal_0": 0, "normal_1": 0, "normal_2": 1}, points[0].Attributes)
+
+	_, err = NewPCDReader(strings.NewReader("FIELDS x y z\nSIZE 4 4 4\nTYPE F F F\nPOINTS 1\nDATA binary_compressed\n"))
+	equals(t, true, err != nil)
+	_, err = NewPCDReader(strings.NewReader("FIELDS x y\nSIZE 4 4\nTYPE F F\nPOINTS 1\nDATA ascii\n"))
+	equals(t, true, err != nil)
+	_, err = NewPCDReader(strings.NewReader("FIELDS x y z\nSIZE 4 4\nTYPE F F F\nPOINTS 1\nDATA ascii\n"))
+	equals(t, true, err != nil)
+	for _, line := range []string{"WIDTH", "HEIGHT", "POINTS", "POINTS 1 2"} {
+		_, err = NewPCDReader(strings.NewReader("FIELDS x y z\nSIZE 4 4 4\nTYPE F F F\n" + line + "\nDATA ascii\n"))
+		equals(t, true, err != nil)
+	}
+}
+
+func TestPCDBinary(t *testing.T) {
+	var b bytes.Buffer
+	b.WriteString("VERSION 0.7\nFIELDS x y z rgba label\nSIZE 4 4 8 4 2\nTYPE F F F U I\nWIDTH 2\nHEIGHT 1\nDATA binary\n")
+	le := binary.LittleEndian
+	for i := 0; i < 2; i++ {
+		binary.Write(&b, le, float32(i))
+		binary.Write(&b, le, float32(2))
+		binary.Write(&b, le, float64(-3))
+		binary.Write(&b, le, uint32(0x80102030))
+		binary.Write(&b, le, int16(-7))
+	}
+	r, err := NewPCDReader(&b)
+	points := readAll(t, r, err)
+	equals(t, 2, len(points))
+	equals(t, 1., points[1].Position.X)
+	equals(t, -3., points[1].Position.Z)
+	equals(t, Attributes{"red": 0x10, "green": 0x20, "blue": 0x30, "alpha": 0x80, "label": -7}, points[1].Attributes)
+
+	// Truncated
+	b.Reset()
+	b.WriteString("FIELDS x y z\nSIZE 4 4 4\nTYPE F F F\nPOINTS 2\nDATA binary\n")
+	binary.Write(&b, le, []float32{1, 2, 3, math.Pi})
+	r, err = NewPCDReader(&b)
+	equals(t, nil, err)
+	_, err = r.Read()
+	equals(t, nil, err)
+	_, err = r.Read()
+	equals(t, true, err != nil)
+}
@@ -0,0 +1,300 @@
+package pointcloud
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// plyProperty is a scalar or list property of a PLY element
+type plyProperty struct {
+	name      string
+	typ       string
+	list      bool
+	countType string
+}
+
+type plyElement struct {
+	name       string
+	count      int
+	properties []plyProperty
+}
+
+// plySizes are the byte sizes of the PLY scalar types, old and new names
+var plySizes = map[string]int{
+	"char": 1, "int8": 1, "uchar": 1, "uint8": 1,
+	"short": 2, "int16": 2, "ushort": 2, "uint16": 2,
+	"int": 4, "int32": 4, "uint": 4, "uint32": 4,
+	"float": 4, "float32": 4, "double": 8, "float64": 8,
+}
+
+type plyReader struct {
+	r         *bufio.Reader
+	order     binary.ByteOrder // nil for ascii
+	vertex    plyElement
+	remaining int
+	fields    []string
+	buf       [8]byte
+}
+
+// NewPLYReader reads the header of an ascii or binary PLY file, the points are the vertex element.
+// Properties other than x, y and z are the attributes, list properties are ignored.
+func NewPLYReader(r io.Reader) (Reader, error) {
+	pr := &plyReader{r: bufio.NewReader(r)}
+	line, err := pr.r.ReadString('\n')
+	if err != nil || strings.TrimSpace(line) != "ply" {
+		return nil, fmt.Errorf("not a PLY file")
+	}
+
+	var elements []plyElement
+	for {
+		line, err := pr.r.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("invalid PLY header: %v", err)
+		}
+		f := strings.Fields(line)
+		if len(f) == 0 {
+			continue
+		}
+		switch f[0] {
+		case "format":
+			if len(f) != 3 {
+				return nil, fmt.Errorf("invalid PLY format %q", line)
+			}
+			switch f[1] {
+			case "ascii":
+			case "binary_little_endian":
+				pr.order = binary.LittleEndian
+			case "binary_big_endian":
+				pr.order = binary.BigEndian
+			default:
+				return nil, fmt.Errorf("unknown PLY format %v", f[1])
+			}
+		case "element":
+			if len(f) != 3 {
+				return nil, fmt.Errorf("invalid PLY element %q", line)
+			}
+			n, err := strconv.Atoi(f[2])
+			if err != nil {
+				return nil, fmt.Errorf("invalid PLY element count: %v", err)
+			}
+			elements = append(elements, plyElement{name: f[1], count: n})
+		case "property":
+			if len(elements) == 0 {
+				return nil, fmt.Errorf("PLY property outside of an element")
+			}
+			p := plyProperty{name: f[len(f)-1], typ: f[1]}
+			if f[1] == "list" {
+				if len(f) != 5 {
+					return nil, fmt.Errorf("invalid PLY list property %q", line)
+				}
+				p = plyProperty{name: f[4], list: true, countType: f[2], typ: f[3]}
+			}
+			if _, ok := plySizes[p.typ]; !ok {
+				return nil, fmt.Errorf("unknown PLY type %v", p.typ)
+			}
+			e := &elements[len(elements)-1]
+			e.properties = append(e.properties, p)
+		}
+		if f[0] == "end_header" {
+			break
+		}
+	}
+
+	// Skip the elements preceding the vertices, usually none
+	for _, e := range elements {
+		if e.name == "vertex" {
+			pr.vertex = e
+			pr.remaining = e.count
+			break
+		}
+		for i := 0; i < e.count; i++ {
+			if _, err := pr.readElement(e); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if pr.vertex.name == "" {
+		return nil, fmt.Errorf("no vertex element in PLY file")
+	}
+	found := 0
+	for _, p := range pr.vertex.properties {
+		switch {
+		case p.list:
+		case p.name == "x" || p.name == "y" || p.name == "z":
+			found++
+		default:
+			pr.fields = append(pr.fields, p.name)
+		}
+	}
+	if found != 3 {
+		return nil, fmt.Errorf("PLY vertex element must have x, y and z properties")
+	}
+	return pr, nil
+}
+
+func (pr *plyReader) Fields() []string {
+	return pr.fields
+}
+
+func (pr *plyReader) Read() (Point, error) {
+	if pr.remaining == 0 {
+		return Point{}, io.EOF
+	}
+	pr.remaining--
+	values, err := pr.readElement(pr.vertex)
+	if err != nil {
+		return Point{}, err
+	}
+	p := Point{Attributes: make(Attributes, len(pr.fields))}
+	for i, prop := range pr.vertex.properties {
+		switch prop.name {
+		case "x":
+			p.Position.X = values[i]
+		case "y":
+			p.Position.Y = values[i]
+		case "z":
+			p.Position.Z = values[i]
+		default:
+			if !prop.list {
+				p.Attributes[prop.name] = values[i]
+			}
+		}
+	}
+	return p, nil
+}
+
+// readElement reads one record of e, list properties are read and their value set to 0
+func (pr *plyReader) readElement(e plyElement) ([]float64, error) {
+	values := make([]float64, len(e.properties))
+	if pr.order == nil {
+		line, err := pr.r.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return nil, unexpectedEOF(err)
+		}
+		f := strings.Fields(line)
+		for i, p := range e.properties {
+			if len(f) == 0 {
+				return nil, fmt.Errorf("PLY %v: missing values in %q", e.name, line)
+			}
+			n := 1
+			if p.list {
+				count, err := strconv.Atoi(f[0])
+				if err != nil {
+					return nil, fmt.Errorf("PLY %v: invalid list count: %v", e.name, err)
+				}
+				f = f[1:]
+				n = count
+			}
+			if len(f) < n {
+				return nil, fmt.Errorf("PLY %v: missing values in %q", e.name, line)
+			}
+			if !p.list {
+				v, err := strconv.ParseFloat(f[0], 64)
+				if err != nil {
+					return nil, fmt.Errorf("PLY %v: %v", e.name, err)
+				}
+				values[i] = v
+			}
+			f = f[n:]
+		}
+		return values, nil
+	}
+
+	for i, p := range e.properties {
+		if !p.list {
+			v, err := pr.readBinary(p.typ)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = v
+			continue
+		}
+		count, err := pr.readBinary(p.countType)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := pr.r.Discard(int(count) * plySizes[p.typ]); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+	}
+	return values, nil
+}
+
+func (pr *plyReader) readBinary(typ string) (float64, error) {
+	b := pr.buf[:plySizes[typ]]
+	if _, err := io.ReadFull(pr.r, b); err != nil {
+		return 0, unexpectedEOF(err)
+	}
+	switch typ {
+	case "char", "int8":
+		return float64(int8(b[0])), nil
+	case "uchar", "uint8":
+		return float64(b[0]), nil
+	case "short", "int16":
+		return float64(int16(pr.order.Uint16(b))), nil
+	case "ushort", "uint16":
+		return float64(pr.order.Uint16(b)), nil
+	case "int", "int32":
+		return float64(int32(pr.order.Uint32(b))), nil
+	case "uint", "uint32":
+		return float64(pr.order.Uint32(b)), nil
+	case "float", "float32":
+		return float64(math.Float32frombits(pr.order.Uint32(b))), nil
+	}
+	return math.Float64frombits(pr.order.Uint64(b)), nil
+}
+
+// WritePLY writes the points as an ascii PLY file. Positions are doubles,
+// the colors uchar and the other attributes floats. Missing attributes are written as 0.
+func WritePLY(w io.Writer, points []Point) error {
+	bw := bufio.NewWriter(w)
+	names := fields(points)
+	fmt.Fprintf(bw, "ply\nformat ascii 1.0\nelement vertex %v\n", len(points))
+	fmt.Fprintf(bw, "property double x\nproperty double y\nproperty double z\n")
+	for _, name := range names {
+		typ := "float"
+		if isColor(name) {
+			typ = "uchar"
+		}
+		fmt.Fprintf(bw, "property %v %v\n", typ, name)
+	}
+	fmt.Fprintf(bw, "end_header\n")
+	for _, p := range points {
+		writeRow(bw, p, names)
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+// writeRow writes the position and the attributes of p separated by spaces
+func writeRow(w *bufio.Writer, p Point, names []string) {
+	w.WriteString(formatFloat(p.Position.X))
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(p.Position.Y))
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(p.Position.Z))
+	for _, name := range names {
+		w.WriteByte(' ')
+		w.WriteString(formatFloat(p.Attributes[name]))
+	}
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func isColor(name string) bool {
+	return name == "red" || name == "green" || name == "blue" || name == "alpha"
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,80 @@
+package pointcloud
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestPLY(t *testing.T) {
+	var b bytes.Buffer
+	equals(t, nil, WritePLY(&b, testPoints()))
+	r, err := NewPLYReader(&b)
+	equals(t, []string{"red", "green", "blue", "intensity"}, r.Fields())
+	equals(t, testPoints(), readAll(t, r, err))
+
+	// Faces before the vertices are skipped
+	r, err = NewPLYReader(strings.NewReader(`ply
+format ascii 1.0
+comment made by hand
+element face 1
+property list uchar int vertex_indices
+element vertex 2
+property float x
+property float y
+property float z
+property list uchar float weights
+property float nx
+end_header
+3 0 1 2
+1 2 3 2 0.5 0.5 7
+4 5 6 0 8
+`))
+	points := readAll(t, r, err)
+	equals(t, 2, len(points))
+	equals(t, Attributes{"nx": 8}, points[1].Attributes)
+
+	for _, header := range []string{"", "plyx\n", "ply\nformat binary_middle_endian 1.0\nend_header\n", "ply\nformat ascii 1.0\nelement vertex 1\nproperty float x\nend_header\n"} {
+		_, err := NewPLYReader(strings.NewReader(header))
+		equals(t, true, err != nil)
+	}
+	// Truncated
+	r, err = NewPLYReader(strings.NewReader("ply\nformat ascii 1.0\nelement vertex 2\nproperty float x\nproperty float y\nproperty float z\nend_header\n1 2 3\n"))
+	equals(t, nil, err)
+	_, err = r.Read()
+	equals(t, nil, err)
+	_, err = r.Read()
+	equals(t, true, err != nil)
+}
+
+func TestPLYBinary(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		name := "binary_little_endian"
+		if order == binary.BigEndian {
+			name = "binary_big_endian"
+		}
+		var b bytes.Buffer
+		b.WriteString("ply\nformat " + name + " 1.0\nelement vertex 2\n" +
+			"property float x\nproperty float y\nproperty double z\nproperty uchar red\nproperty short s\n" +
+			"property list uchar int idx\nproperty uint u\nend_header\n")
+		for i := 0; i < 2; i++ {
+			binary.Write(&b, order, float32(i))
+			binary.Write(&b, order, float32(-1.5))
+			binary.Write(&b, order, float64(1e10))
+			binary.Write(&b, order, uint8(200))
+			binary.Write(&b, order, int16(-3))
+			binary.Write(&b, order, uint8(2))
+			binary.Write(&b, order, []int32{7, 8})
+			binary.Write(&b, order, uint32(math.MaxUint32))
+		}
+		r, err := NewPLYReader(&b)
+		points := readAll(t, r, err)
+		equals(t, 2, len(points))
+		equals(t, 1., points[1].Position.X)
+		equals(t, -1.5, points[1].Position.Y)
+		equals(t, 1e10, points[1].Position.Z)
+		equals(t, Attributes{"red": 200, "s": -3, "u": math.MaxUint32}, points[1].Attributes)
+	}
+}
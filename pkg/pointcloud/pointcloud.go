@@ -0,0 +1,150 @@
+// Package pointcloud streams PLY, ASCII XYZ and PCD point clouds into an Octree, and writes them back.
+//
+// Points become objects centered on their position, their attributes
+// (color, intensity, normal...) being the object data.
+package pointcloud
+
+import (
+	"encoding/gob"
+	"io"
+	"math"
+	"sort"
+
+	octree "github.com/louis030195/octree/pkg"
+	"github.com/louis030195/protometry/api/vector3"
+	"github.com/louis030195/protometry/api/volume"
+)
+
+func init() {
+	// So trees of points can be saved as snapshots
+	gob.Register(Attributes{})
+}
+
+// Attributes are the per point values other than the position, keyed by field name
+// as found in the file, e.g. red, green, blue, intensity, nx, ny, nz
+type Attributes map[string]float64
+
+// Color returns the red, green and blue attributes
+func (a Attributes) Color() (r, g, b uint8, ok bool) {
+	red, okR := a["red"]
+	green, okG := a["green"]
+	blue, okB := a["blue"]
+	return uint8(red), uint8(green), uint8(blue), okR && okG && okB
+}
+
+// Normal returns the nx, ny and nz attributes
+func (a Attributes) Normal() (vector3.Vector3, bool) {
+	x, okX := a["nx"]
+	y, okY := a["ny"]
+	z, okZ := a["nz"]
+	return *vector3.NewVector3(x, y, z), okX && okY && okZ
+}
+
+// Intensity returns the intensity attribute
+func (a Attributes) Intensity() (float64, bool) {
+	i, ok := a["intensity"]
+	return i, ok
+}
+
+// Point is a position and its attributes
+type Point struct {
+	Position   vector3.Vector3
+	Attributes Attributes
+}
+
+// Reader streams the points of a cloud
+type Reader interface {
+	// Read returns the next point, io.EOF once all the points have been read
+	Read() (Point, error)
+	// Fields returns the names of the attributes, in file order
+	Fields() []string
+}
+
+// NewObject returns a cubic object of the given size centered on the point, with its attributes as data
+func NewObject(p Point, size float64) *octree.Object {
+	return octree.NewObjectCube(p.Attributes, p.Position.X, p.Position.Y, p.Position.Z, size)
+}
+
+// Insert streams all the points of r into o, as objects of the given size.
+// Points outside the tree are skipped and counted.
+func Insert(o *octree.Octree, r Reader, size float64) (inserted, skipped int, err error) {
+	for {
+		p, err := r.Read()
+		if err == io.EOF {
+			return inserted, skipped, nil
+		}
+		if err != nil {
+			return inserted, skipped, err
+		}
+		if o.Insert(*NewObject(p, size)) {
+			inserted++
+		} else {
+			skipped++
+		}
+	}
+}
+
+// Bounds streams all the points of r and returns their bounding box and their number
+func Bounds(r Reader) (*volume.Box, int, error) {
+	bounds := volume.NewBoxMinMax(math.Inf(1), math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1), math.Inf(-1))
+	n := 0
+	for {
+		p, err := r.Read()
+		if err == io.EOF {
+			return bounds, n, nil
+		}
+		if err != nil {
+			return nil, n, err
+		}
+		bounds.EncapsulatePoint(p.Position)
+		n++
+	}
+}
+
+// FromObjects converts objects, e.g. a query result, back to points located at the center of their bounds.
+// Attributes are kept when the object data are Attributes.
+func FromObjects(objects []octree.Object) []Point {
+	points := make([]Point, len(objects))
+	for i := range objects {
+		a, _ := objects[i].Data.(Attributes)
+		points[i] = Point{Position: objects[i].Bounds.GetCenter(), Attributes: a}
+	}
+	return points
+}
+
+// fields returns the sorted union of the attributes of points
+func fields(points []Point) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, p := range points {
+		for name := range p.Attributes {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sortFields(names)
+	return names
+}
+
+// fieldOrder ranks the well known attributes first when writing files
+var fieldOrder = map[string]int{
+	"red": 1, "green": 2, "blue": 3, "alpha": 4,
+	"nx": 5, "ny": 6, "nz": 7,
+	"intensity": 8,
+}
+
+func sortFields(names []string) {
+	sort.Slice(names, func(i, j int) bool {
+		ri, rj := fieldOrder[names[i]], fieldOrder[names[j]]
+		if ri == 0 || rj == 0 {
+			if ri != rj {
+				// Known fields first
+				return ri != 0
+			}
+			return names[i] < names[j]
+		}
+		return ri < rj
+	})
+}
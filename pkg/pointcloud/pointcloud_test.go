@@ -0,0 +1,93 @@
+package pointcloud
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	octree "github.com/louis030195/octree/pkg"
+	"github.com/louis030195/protometry/api/vector3"
+	"github.com/louis030195/protometry/api/volume"
+)
+
+// equals fails the test if exp is not equal to act.
+func equals(tb testing.TB, exp, act interface{}) {
+	tb.Helper()
+	if !reflect.DeepEqual(exp, act) {
+		tb.Fatalf("\n\texp: %#v\n\n\tgot: %#v", exp, act)
+	}
+}
+
+// readAll drains a reader
+func readAll(t *testing.T, r Reader, err error) []Point {
+	t.Helper()
+	equals(t, nil, err)
+	var points []Point
+	for {
+		p, err := r.Read()
+		if err != nil {
+			equals(t, "EOF", err.Error())
+			return points
+		}
+		points = append(points, p)
+	}
+}
+
+func testPoints() []Point {
+	return []Point{
+		{Position: *vector3.NewVector3(0, 0, 0), Attributes: Attributes{"red": 255, "green": 0, "blue": 12, "intensity": 0.5}},
+		{Position: *vector3.NewVector3(1.5, -2, 3), Attributes: Attributes{"red": 1, "green": 2, "blue": 3, "intensity": 1}},
+		{Position: *vector3.NewVector3(-4, 5, 6.25), Attributes: Attributes{"red": 0, "green": 255, "blue": 0, "intensity": 0}},
+	}
+}
+
+func TestInsert(t *testing.T) {
+	var b bytes.Buffer
+	_, err := WriteXYZ(&b, testPoints())
+	equals(t, nil, err)
+	text := b.String()
+
+	bounds, n, err := Bounds(NewXYZReader(strings.NewReader(text)))
+	equals(t, nil, err)
+	equals(t, 3, n)
+	equals(t, true, volume.NewBoxMinMax(-4, -2, 0, 1.5, 5, 6.25).Equal(*bounds))
+
+	o := octree.NewOctree(volume.NewBoxOfSize(0, 0, 0, 10))
+	inserted, skipped, err := Insert(o, NewXYZReader(strings.NewReader(text), "red", "green", "blue", "intensity"), 0.1)
+	equals(t, nil, err)
+	equals(t, 2, inserted)
+	equals(t, 1, skipped) // z = 6.25 is outside
+
+	colliding := o.GetColliding(*volume.NewBoxOfSize(1.5, -2, 3, 0.5))
+	equals(t, 1, len(colliding))
+	r, g, bl, ok := colliding[0].Data.(Attributes).Color()
+	equals(t, true, ok)
+	equals(t, [3]uint8{1, 2, 3}, [3]uint8{r, g, bl})
+
+	// Query results go back to points, and the tree can be saved with its attributes
+	points := FromObjects(colliding)
+	equals(t, *vector3.NewVector3(1.5, -2, 3), points[0].Position)
+	equals(t, 1., points[0].Attributes["intensity"])
+	b.Reset()
+	equals(t, nil, o.Save(&b))
+	loaded, err := octree.Load(&b)
+	equals(t, nil, err)
+	equals(t, colliding[0].Data, loaded.GetColliding(*volume.NewBoxOfSize(1.5, -2, 3, 0.5))[0].Data)
+}
+
+func TestAttributes(t *testing.T) {
+	a := Attributes{"nx": 0, "ny": 1, "nz": 0, "intensity": 3}
+	n, ok := a.Normal()
+	equals(t, true, ok)
+	equals(t, *vector3.NewVector3(0, 1, 0), n)
+	i, ok := a.Intensity()
+	equals(t, true, ok)
+	equals(t, 3., i)
+	_, _, _, ok = a.Color()
+	equals(t, false, ok)
+
+	names := []string{"zeta", "intensity", "blue", "alpha", "nx", "red", "green", "a"}
+	sortFields(names)
+	equals(t, []string{"red", "green", "blue", "alpha", "nx", "intensity", "a", "zeta"}, names)
+}
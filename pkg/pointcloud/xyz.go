@@ -0,0 +1,72 @@
+package pointcloud
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type xyzReader struct {
+	scanner *bufio.Scanner
+	fields  []string
+	line    int
+}
+
+// NewXYZReader reads whitespace or comma separated x y z points, one per line.
+// XYZ files have no header, fields names the columns following x y z, e.g. "red", "green", "blue";
+// columns without a name are ignored. Empty lines and lines starting with # or // are skipped.
+func NewXYZReader(r io.Reader, fields ...string) Reader {
+	return &xyzReader{scanner: bufio.NewScanner(r), fields: fields}
+}
+
+func (xr *xyzReader) Fields() []string {
+	return xr.fields
+}
+
+func (xr *xyzReader) Read() (Point, error) {
+	for xr.scanner.Scan() {
+		xr.line++
+		line := strings.TrimSpace(xr.scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") {
+			continue
+		}
+		f := strings.FieldsFunc(line, func(r rune) bool {
+			return r == ' ' || r == '\t' || r == ','
+		})
+		if len(f) < 3+len(xr.fields) {
+			return Point{}, fmt.Errorf("XYZ line %v: expected at least %v columns, got %v", xr.line, 3+len(xr.fields), len(f))
+		}
+		values := make([]float64, 3+len(xr.fields))
+		for i := range values {
+			v, err := strconv.ParseFloat(f[i], 64)
+			if err != nil {
+				return Point{}, fmt.Errorf("XYZ line %v: %v", xr.line, err)
+			}
+			values[i] = v
+		}
+		p := Point{Attributes: make(Attributes, len(xr.fields))}
+		p.Position.X, p.Position.Y, p.Position.Z = values[0], values[1], values[2]
+		for i, name := range xr.fields {
+			p.Attributes[name] = values[3+i]
+		}
+		return p, nil
+	}
+	if err := xr.scanner.Err(); err != nil {
+		return Point{}, err
+	}
+	return Point{}, io.EOF
+}
+
+// WriteXYZ writes the points as space separated x y z followed by their attributes,
+// the order of the columns is returned since XYZ files don't have any header.
+func WriteXYZ(w io.Writer, points []Point) ([]string, error) {
+	bw := bufio.NewWriter(w)
+	names := fields(points)
+	for _, p := range points {
+		writeRow(bw, p, names)
+		bw.WriteByte('\n')
+	}
+	return names, bw.Flush()
+}
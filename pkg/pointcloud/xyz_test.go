@@ -0,0 +1,25 @@
+package pointcloud
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestXYZ(t *testing.T) {
+	points := readAll(t, NewXYZReader(strings.NewReader("# x y z i\n1 2 3 0.5 9\n\n// comment\n4,5,6,1,9\n"), "intensity"), nil)
+	equals(t, 2, len(points))
+	equals(t, 4., points[1].Position.X)
+	equals(t, Attributes{"intensity": 0.5}, points[0].Attributes)
+
+	_, err := NewXYZReader(strings.NewReader("1 2\n")).Read()
+	equals(t, true, err != nil)
+	_, err = NewXYZReader(strings.NewReader("1 2 a\n")).Read()
+	equals(t, true, err != nil)
+
+	var b bytes.Buffer
+	names, err := WriteXYZ(&b, testPoints())
+	equals(t, nil, err)
+	equals(t, []string{"red", "green", "blue", "intensity"}, names)
+	equals(t, testPoints(), readAll(t, NewXYZReader(&b, names...), nil))
+}
@@ -0,0 +1,120 @@
+package octree
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/louis030195/protometry/api/vector3"
+	"github.com/louis030195/protometry/api/volume"
+)
+
+// RaycastHit is an object crossed by a ray
+type RaycastHit struct {
+	Object Object
+	// Distance from the ray origin to the entry point in the object bounds
+	Distance float64
+}
+
+// Raycast returns the objects whose bounds are crossed by the ray starting at origin,
+// going toward direction, up to maxDistance. Hits are sorted by increasing distance.
+// A maxDistance <= 0 means the ray is infinite.
+func (o *Octree) Raycast(origin, direction vector3.Vector3, maxDistance float64) []RaycastHit {
+	return o.RaycastMask(origin, direction, maxDistance, AllLayers)
+}
+
+// RaycastMask is Raycast restricted to the objects sharing a layer with mask
+func (o *Octree) RaycastMask(origin, direction vector3.Vector3, maxDistance float64, mask uint64) []RaycastHit {
+	length := direction.Norm2()
+	if length == 0 {
+		return nil
+	}
+	direction = direction.Times(1 / length)
+	if maxDistance <= 0 {
+		maxDistance = math.Inf(1)
+	}
+	if o.metrics != nil {
+		defer o.metrics.observe(queryRaycast, time.Now())
+	}
+	r := newRay(origin, direction, maxDistance)
+	r.mask = mask
+	hits := o.root.raycast(r, nil)
+	sort.SliceStable(hits, func(i, j int) bool {
+		return hits[i].Distance < hits[j].Distance
+	})
+	return hits
+}
+
+func (n *Node) raycast(r ray, hits []RaycastHit) []RaycastHit {
+	n.metrics().add(countVisits)
+	if !matchLayers(n.layers, r.mask) {
+		return hits
+	}
+	if _, ok := r.intersects(n.region); !ok {
+		return hits
+	}
+	for _, obj := range n.objects {
+		if !matchLayers(obj.Layers, r.mask) {
+			continue
+		}
+		if d, ok := r.intersects(obj.Bounds); ok {
+			hits = append(hits, RaycastHit{Object: obj, Distance: d})
+		}
+	}
+	if n.children != nil {
+		for i := range n.children {
+			hits = n.children[i].raycast(r, hits)
+		}
+	}
+	return hits
+}
+
+// ray is a normalized half-line clipped at maxDistance,
+// with the direction inverse precomputed for the slab test
+type ray struct {
+	origin      vector3.Vector3
+	direction   vector3.Vector3
+	invDir      vector3.Vector3
+	maxDistance float64
+	// mask filters the objects by layers, AllLayers by default
+	mask uint64
+}
+
+func newRay(origin, direction vector3.Vector3, maxDistance float64) ray {
+	return ray{
+		origin:      origin,
+		direction:   direction,
+		invDir:      *vector3.NewVector3(1/direction.X, 1/direction.Y, 1/direction.Z),
+		maxDistance: maxDistance,
+		mask:        AllLayers,
+	}
+}
+
+// intersects is the slab test, it returns the distance at which the ray enters the box,
+// 0 if the origin is inside
+func (r ray) intersects(b volume.Box) (float64, bool) {
+	tMin, tMax := 0., r.maxDistance
+	for _, axis := range [3][4]float64{
+		{r.origin.X, r.invDir.X, b.Min.X, b.Max.X},
+		{r.origin.Y, r.invDir.Y, b.Min.Y, b.Max.Y},
+		{r.origin.Z, r.invDir.Z, b.Min.Z, b.Max.Z},
+	} {
+		origin, inv, min, max := axis[0], axis[1], axis[2], axis[3]
+		if math.IsInf(inv, 0) {
+			// Parallel to the slab, either always inside or never
+			if origin < min || origin > max {
+				return 0, false
+			}
+			continue
+		}
+		t1, t2 := (min-origin)*inv, (max-origin)*inv
+		if t1 > t2 {
+			t1, t2 = t2, t1
+		}
+		tMin, tMax = math.Max(tMin, t1), math.Min(tMax, t2)
+		if tMin > tMax {
+			return 0, false
+		}
+	}
+	return tMin, true
+}
@@ -0,0 +1,36 @@
+package octree
+
+import (
+	"testing"
+
+	"github.com/louis030195/protometry/api/vector3"
+	"github.com/louis030195/protometry/api/volume"
+)
+
+func TestOctree_Raycast(t *testing.T) {
+	o := NewOctree(volume.NewBoxOfSize(0, 0, 0, 100))
+	for i := 0.; i < 10; i++ {
+		equals(t, true, o.Insert(*NewObjectCube(int(i), i*4, 0, 0, 2)))
+	}
+	equals(t, true, o.Insert(*NewObjectCube(-1, 0, 10, 0, 2)))
+
+	hits := o.Raycast(*vector3.NewVector3(-10, 0, 0), *vector3.NewVector3(1, 0, 0), 0)
+	equals(t, 10, len(hits))
+	for i := range hits {
+		equals(t, i, hits[i].Object.Data)
+	}
+	equals(t, 9., hits[0].Distance)
+
+	// Not normalized direction, limited distance
+	hits = o.Raycast(*vector3.NewVector3(-10, 0, 0), *vector3.NewVector3(5, 0, 0), 13)
+	equals(t, 2, len(hits))
+
+	// Origin inside an object
+	hits = o.Raycast(*vector3.NewVector3(0, 10, 0), *vector3.NewVector3(0, 1, 0), 0)
+	equals(t, 1, len(hits))
+	equals(t, 0., hits[0].Distance)
+
+	// Missing everything
+	equals(t, 0, len(o.Raycast(*vector3.NewVector3(0, -10, 0), *vector3.NewVector3(0, 0, 1), 0)))
+	equals(t, 0, len(o.Raycast(*vector3.NewVector3Zero(), *vector3.NewVector3Zero(), 0)))
+}
@@ -0,0 +1,110 @@
+package octree
+
+import "github.com/louis030195/protometry/api/volume"
+
+// Rebuild reconstructs the tree from its current objects, as if they were inserted in a new tree.
+// The objects which no longer fit the region of the tree, e.g. after modifying their bounds in place, are dropped and returned
+func (o *Octree) Rebuild() []Object {
+	root, dropped := o.build(o.root.region, o.balance21, o.getAggregators(), o.root.getAllObjects())
+	o.root = root
+	o.version++
+	return dropped
+}
+
+// build returns a new root of region holding objects, and the objects outside the region.
+// The settings of the tree are given rather than read, the tree may be used meanwhile, see RebuildAsync
+func (o *Octree) build(region volume.Box, balance21 bool, aggregators []Aggregator, objects []Object) (*Node, []Object) {
+	root := &Node{region: region, tree: o}
+	var inside, dropped []Object
+	for _, obj := range objects {
+		if obj.Bounds.Fit(root.region) {
+			inside = append(inside, obj)
+		} else {
+			dropped = append(dropped, obj)
+		}
+	}
+	root.build(inside, aggregators)
+	if balance21 {
+		balance(root.leaves())
+	}
+	return root, dropped
+}
+
+// build distributes objects, all fitting in n, in the subtree of n.
+// A node is split when it would hold more than the split threshold, like insert does,
+// but each object is compared with the children once per level
+func (n *Node) build(objects []Object, aggregators []Aggregator) {
+	if len(objects) <= n.tree.getSplitThreshold() {
+		n.objects = objects
+		n.updateLayers()
+		n.aggregate(aggregators)
+		return
+	}
+	n.split()
+	var children [8][]Object
+	n.objects = nil
+	for _, obj := range objects {
+		pushed := false
+		for i := range n.children {
+			if obj.Bounds.Fit(n.children[i].region) {
+				children[i] = append(children[i], obj)
+				pushed = true
+				break
+			}
+		}
+		if !pushed {
+			n.objects = append(n.objects, obj)
+		}
+	}
+	for i := range n.children {
+		n.children[i].build(children[i], aggregators)
+	}
+	n.updateLayers()
+	n.aggregate(aggregators)
+}
+
+// PendingRebuild is a tree rebuilt in the background, see RebuildAsync
+type PendingRebuild struct {
+	tree    *Octree
+	version uint64
+	root    *Node
+	dropped []Object
+	done    chan struct{}
+}
+
+// RebuildAsync starts rebuilding the tree in a new goroutine, the tree can be used meanwhile.
+// The region, the settings and the objects are copied before returning, bounds included as Move updates them
+// in place, so nothing else may access the tree during this call. Data is shared with the copies, the aggregators
+// must not read the Data modified before the rebuild is done. Swap replaces the root with the rebuilt one once done
+func (o *Octree) RebuildAsync() *PendingRebuild {
+	r := &PendingRebuild{tree: o, version: o.version, done: make(chan struct{})}
+	region, balance21, aggregators := o.root.region, o.balance21, o.getAggregators()
+	objects := o.root.getAllObjects()
+	for i := range objects {
+		objects[i].Bounds = volume.Box{Min: objects[i].Bounds.Min.Clone(), Max: objects[i].Bounds.Max.Clone()}
+	}
+	go func() {
+		r.root, r.dropped = o.build(region, balance21, aggregators, objects)
+		close(r.done)
+	}()
+	return r
+}
+
+// Done is closed once the rebuilt tree is ready to be swapped
+func (r *PendingRebuild) Done() <-chan struct{} {
+	return r.done
+}
+
+// Swap waits for the rebuild to end and replaces the root of the tree with the rebuilt one in a single step,
+// it must be called where the tree could be modified, e.g. holding the lock protecting it.
+// It returns false and leaves the tree untouched when the tree was modified since RebuildAsync,
+// the rebuilt tree being outdated. Otherwise the objects which no longer fit the region are returned, as with Rebuild
+func (r *PendingRebuild) Swap() ([]Object, bool) {
+	<-r.done
+	if r.tree.version != r.version {
+		return nil, false
+	}
+	r.tree.root = r.root
+	r.tree.version++
+	return r.dropped, true
+}
@@ -0,0 +1,130 @@
+package octree
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/louis030195/protometry/api/volume"
+)
+
+// degradedTree returns a tree with many empty leaves, its merges being deferred, and the remaining objects
+func degradedTree(t *testing.T) (*Octree, []Object) {
+	o := NewOctree(volume.NewBoxOfSize(0, 0, 0, 100), WithDeferredMerge())
+	r := rand.New(rand.NewSource(1))
+	var objects []Object
+	for i := 0; i < 1000; i++ {
+		obj := *NewObjectCube(i, r.Float64()*98-49, r.Float64()*98-49, r.Float64()*98-49, 1)
+		equals(t, true, o.Insert(obj))
+		objects = append(objects, obj)
+	}
+	for _, obj := range objects[50:] {
+		equals(t, true, o.Remove(obj))
+	}
+	return o, objects[:50]
+}
+
+func ids(objects []Object) []uint64 {
+	var ids []uint64
+	for _, obj := range objects {
+		ids = append(ids, obj.ID())
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func TestOctree_Compact(t *testing.T) {
+	o, objects := degradedTree(t)
+	before := o.Stats()
+	o.Compact()
+	after := o.Stats()
+	equals(t, nil, o.Validate())
+	equals(t, true, after.Nodes < before.Nodes)
+	equals(t, true, after.EmptyLeaves < before.EmptyLeaves)
+	equals(t, ids(objects), ids(o.GetAllObjects()))
+
+	// An object stranded at the root is pushed down
+	o = NewOctree(volume.NewBoxOfSize(0, 0, 0, 8))
+	for i := 0; i < CAPACITY+1; i++ {
+		equals(t, true, o.Insert(*NewObjectCube(i, float64(i%2)*4-2, 2, 2, 1)))
+	}
+	stranded := *NewObjectCube(0, 2, 2, 2, 0.5)
+	o.root.objects = append(o.root.objects, stranded)
+	equals(t, false, o.Validate() == nil)
+	o.Compact()
+	equals(t, nil, o.Validate())
+	equals(t, 0, len(o.root.objects))
+	equals(t, CAPACITY+2, len(o.GetAllObjects()))
+}
+
+func TestOctree_Rebuild(t *testing.T) {
+	o, objects := degradedTree(t)
+	compacted, _ := degradedTree(t)
+	compacted.Compact()
+
+	equals(t, 0, len(o.Rebuild()))
+	equals(t, nil, o.Validate())
+	equals(t, ids(objects), ids(o.GetAllObjects()))
+	equals(t, true, o.Stats().Nodes <= compacted.Stats().Nodes)
+
+	// Bounds modified in place
+	obj := o.GetAllObjects()[0]
+	obj.Bounds.Max.X = 1000
+	dropped := o.Rebuild()
+	equals(t, 1, len(dropped))
+	equals(t, obj.ID(), dropped[0].ID())
+	equals(t, len(objects)-1, len(o.GetAllObjects()))
+	equals(t, nil, o.Validate())
+}
+
+func TestOctree_RebuildAsync(t *testing.T) {
+	o, objects := degradedTree(t)
+	r := o.RebuildAsync()
+	<-r.Done()
+	dropped, ok := r.Swap()
+	equals(t, true, ok)
+	equals(t, 0, len(dropped))
+	equals(t, nil, o.Validate())
+	equals(t, ids(objects), ids(o.GetAllObjects()))
+
+	// Modified during the rebuild, the tree is kept
+	r = o.RebuildAsync()
+	root := o.root
+	equals(t, true, o.Remove(objects[0]))
+	_, ok = r.Swap()
+	equals(t, false, ok)
+	equals(t, root, o.root)
+}
+
+func TestOctree_RebuildAsyncMove(t *testing.T) {
+	// Moves update the bounds in place while the rebuild reads its copy, run with -race
+	o := NewOctree(volume.NewBoxOfSize(0, 0, 0, 100))
+	r := rand.New(rand.NewSource(1))
+	objects := make([]*Object, 2000)
+	for i := range objects {
+		objects[i] = NewObjectCube(i, r.Float64()*90-45, r.Float64()*90-45, r.Float64()*90-45, 1)
+		equals(t, true, o.Insert(*objects[i]))
+	}
+	pending := o.RebuildAsync()
+	for _, obj := range objects {
+		equals(t, true, o.Move(obj, r.Float64()*90-45, r.Float64()*90-45, r.Float64()*90-45))
+	}
+	_, ok := pending.Swap()
+	equals(t, false, ok)
+	equals(t, nil, o.Validate())
+}
+
+func TestOctree_RebuildAsyncBalance21(t *testing.T) {
+	// Balance21 and Rebuild replace the settings and the root the rebuild started from, run with -race
+	o := NewOctree(volume.NewBoxOfSize(0, 0, 0, 100))
+	for i := 0; i < 3000; i++ {
+		equals(t, true, o.Insert(*NewObjectCube(i, float64(i%90)-45, float64(i/90%90)-45, 0, 0.5)))
+	}
+	pending := o.RebuildAsync()
+	o.Balance21()
+	equals(t, 0, len(o.Rebuild()))
+	<-pending.Done()
+	_, ok := pending.Swap()
+	equals(t, false, ok)
+	equals(t, nil, o.Validate())
+}
@@ -0,0 +1,164 @@
+package octree
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/louis030195/protometry/api/volume"
+)
+
+// Axis is the normal of a slice plane
+type Axis int
+
+// Axes
+const (
+	AxisX Axis = iota
+	AxisY
+	AxisZ
+)
+
+// sliceRect is a box cut by the slice plane, in plane coordinates
+type sliceRect struct {
+	minU, minV, maxU, maxV float64
+	color                  [3]float32
+}
+
+// Slice is a 2D cross section of an Octree, the nodes crossing the plane are rectangles
+// colored by depth and the objects crossing it filled rectangles.
+// The plane axes are y and z for AxisX, x and z for AxisY and x and y for AxisZ, the v axis going up.
+type Slice struct {
+	Axis          Axis
+	Coordinate    float64
+	Width, Height int
+	region        sliceRect
+	nodes         []sliceRect
+	objects       []sliceRect
+}
+
+var sliceObjectColor = [3]float32{0.2, 0.2, 0.2}
+
+// project returns the rectangle of b in the plane, false if b doesn't cross the plane
+func (a Axis) project(b volume.Box, coordinate float64) (sliceRect, bool) {
+	switch a {
+	case AxisX:
+		return sliceRect{minU: b.Min.Y, minV: b.Min.Z, maxU: b.Max.Y, maxV: b.Max.Z}, b.Min.X <= coordinate && coordinate <= b.Max.X
+	case AxisY:
+		return sliceRect{minU: b.Min.X, minV: b.Min.Z, maxU: b.Max.X, maxV: b.Max.Z}, b.Min.Y <= coordinate && coordinate <= b.Max.Y
+	}
+	return sliceRect{minU: b.Min.X, minV: b.Min.Y, maxU: b.Max.X, maxV: b.Max.Y}, b.Min.Z <= coordinate && coordinate <= b.Max.Z
+}
+
+// RenderSlice cuts the tree with the plane orthogonal to axis at coordinate, the tree region being mapped
+// to an image of width by height pixels. Use SVG or WritePNG to get the picture.
+func (o *Octree) RenderSlice(axis Axis, coordinate float64, width, height int) *Slice {
+	s := &Slice{Axis: axis, Coordinate: coordinate, Width: width, Height: height}
+	s.region, _ = axis.project(o.root.region, coordinate)
+	maxDepth := o.getHeight() - 1
+	o.root.walk(0, func(n *Node, depth int) bool {
+		r, ok := axis.project(n.region, coordinate)
+		if !ok {
+			return false
+		}
+		t := 0.
+		if maxDepth > 0 {
+			t = float64(depth) / float64(maxDepth)
+		}
+		r.color = heatColor(t)
+		s.nodes = append(s.nodes, r)
+		for _, obj := range n.objects {
+			if r, ok := axis.project(obj.Bounds, coordinate); ok {
+				r.color = sliceObjectColor
+				s.objects = append(s.objects, r)
+			}
+		}
+		return true
+	})
+	return s
+}
+
+// toImage converts plane coordinates to image coordinates
+func (s *Slice) toImage(u, v float64) (float64, float64) {
+	x := (u - s.region.minU) / (s.region.maxU - s.region.minU) * float64(s.Width)
+	y := (s.region.maxV - v) / (s.region.maxV - s.region.minV) * float64(s.Height)
+	return x, y
+}
+
+func (s *Slice) imageRect(r sliceRect) (x, y, w, h float64) {
+	x0, y0 := s.toImage(r.minU, r.maxV)
+	x1, y1 := s.toImage(r.maxU, r.minV)
+	return x0, y0, x1 - x0, y1 - y0
+}
+
+func toRGBA(c [3]float32, alpha uint8) color.NRGBA {
+	return color.NRGBA{R: uint8(c[0] * 255), G: uint8(c[1] * 255), B: uint8(c[2] * 255), A: alpha}
+}
+
+func hexColor(c [3]float32) string {
+	rgba := toRGBA(c, 255)
+	return fmt.Sprintf("#%02x%02x%02x", rgba.R, rgba.G, rgba.B)
+}
+
+// SVG returns the slice as an SVG document
+func (s *Slice) SVG() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%v" height="%v" viewBox="0 0 %v %v">`+"\n", s.Width, s.Height, s.Width, s.Height)
+	fmt.Fprintf(&b, `<rect width="100%%" height="100%%" fill="white"/>`+"\n")
+	for _, r := range s.objects {
+		x, y, w, h := s.imageRect(r)
+		fmt.Fprintf(&b, `<rect x="%.2f" y="%.2f" width="%.2f" height="%.2f" fill="%v" fill-opacity="0.5"/>`+"\n", x, y, w, h, hexColor(r.color))
+	}
+	for _, r := range s.nodes {
+		x, y, w, h := s.imageRect(r)
+		fmt.Fprintf(&b, `<rect x="%.2f" y="%.2f" width="%.2f" height="%.2f" fill="none" stroke="%v"/>`+"\n", x, y, w, h, hexColor(r.color))
+	}
+	b.WriteString("</svg>\n")
+	return b.String()
+}
+
+// pixelRect returns the pixels covered by r, the max being exclusive
+func (s *Slice) pixelRect(r sliceRect) image.Rectangle {
+	x, y, w, h := s.imageRect(r)
+	return image.Rect(int(math.Floor(x)), int(math.Floor(y)), int(math.Ceil(x+w)), int(math.Ceil(y+h))).
+		Intersect(image.Rect(0, 0, s.Width, s.Height))
+}
+
+// Image rasterizes the slice, the node borders are one pixel wide
+func (s *Slice) Image() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, s.Width, s.Height))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	for _, r := range s.objects {
+		draw.Draw(img, s.pixelRect(r), image.NewUniform(toRGBA(r.color, 128)), image.Point{}, draw.Over)
+	}
+	for _, r := range s.nodes {
+		p := s.pixelRect(r)
+		if p.Empty() {
+			continue
+		}
+		c := image.NewUniform(toRGBA(r.color, 255))
+		for _, edge := range []image.Rectangle{
+			image.Rect(p.Min.X, p.Min.Y, p.Max.X, p.Min.Y+1),
+			image.Rect(p.Min.X, p.Max.Y-1, p.Max.X, p.Max.Y),
+			image.Rect(p.Min.X, p.Min.Y, p.Min.X+1, p.Max.Y),
+			image.Rect(p.Max.X-1, p.Min.Y, p.Max.X, p.Max.Y),
+		} {
+			draw.Draw(img, edge, c, image.Point{}, draw.Src)
+		}
+	}
+	return img
+}
+
+// WritePNG encodes the rasterized slice as a PNG
+func (s *Slice) WritePNG(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if err := png.Encode(bw, s.Image()); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,45 @@
+package octree
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/louis030195/protometry/api/volume"
+)
+
+func TestOctree_RenderSlice(t *testing.T) {
+	o := NewOctree(volume.NewBoxOfSize(0, 0, 0, 8))
+	for i := 0; i < CAPACITY+1; i++ {
+		equals(t, true, o.Insert(*NewObjectCube(i, 2, 2, float64(i%2)*4-2, 1)))
+	}
+	equals(t, 9, len(o.GetNodes()))
+
+	// The plane crosses the root and the 4 children with z > 0
+	s := o.RenderSlice(AxisZ, 2, 100, 100)
+	equals(t, 5, len(s.nodes))
+	equals(t, 3, len(s.objects))
+	svg := s.SVG()
+	equals(t, true, strings.HasPrefix(svg, "<svg"))
+	equals(t, 5+3+1, strings.Count(svg, "<rect"))
+	// Object at 2,2 of size 1 in the [-4, 4] square
+	equals(t, true, strings.Contains(svg, `<rect x="68.75" y="18.75" width="12.50" height="12.50" fill="#333333"`))
+
+	// Between the children, the plane crosses all of them
+	equals(t, 9, len(o.RenderSlice(AxisX, 0, 10, 10).nodes))
+	equals(t, 0, len(o.RenderSlice(AxisY, 5, 10, 10).nodes))
+
+	var b bytes.Buffer
+	equals(t, nil, s.WritePNG(&b))
+	img, err := png.Decode(&b)
+	equals(t, nil, err)
+	equals(t, 100, img.Bounds().Dx())
+	// Deeper nodes are drawn last, the children borders hide the root one
+	equals(t, color.NRGBA{R: 255, A: 255}, color.NRGBAModel.Convert(img.At(0, 50)))
+	equals(t, color.NRGBA{R: 255, A: 255}, color.NRGBAModel.Convert(img.At(50, 25)))
+	equals(t, color.NRGBA{R: 255, G: 255, B: 255, A: 255}, color.NRGBAModel.Convert(img.At(25, 25)))
+	r, g, bl, _ := img.At(75, 25).RGBA()
+	equals(t, true, r == g && g == bl && r < 0xffff)
+}
@@ -0,0 +1,158 @@
+package octree
+
+import (
+	"math"
+
+	"github.com/louis030195/protometry/api/vector3"
+	"github.com/louis030195/protometry/api/volume"
+)
+
+// SDF is an adaptive signed distance field: the distance to the surface of a mesh, negative inside,
+// sampled at the corners of the leaves of an octree. Cells are subdivided where the field is not linear,
+// near the surface and its creases, and the field is interpolated trilinearly inside a leaf
+type SDF struct {
+	tree *Octree
+	// corners are the distances at the corners of the leaves, corner i being at the max of x if i&4 != 0,
+	// of y if i&2 != 0 and of z if i&1 != 0, as the children of a node
+	corners map[*Node]*[8]float64
+}
+
+// BuildSDF samples the signed distance field of mesh, which must be closed, in a cube slightly larger than it.
+// A cell is subdivided, up to maxDepth, while the trilinear interpolation of its corners differs from
+// the distance by more than errorTolerance at the middle of its edges, faces or at its center
+func BuildSDF(mesh volume.Mesh, maxDepth int, errorTolerance float64) *SDF {
+	m := NewMeshOctree(&mesh)
+	region := m.tree.root.region
+	center, size := region.GetCenter(), region.GetSize()
+	s := &SDF{
+		// The cells hold no object, they must never be merged
+		tree:    NewOctree(volume.NewBoxOfSize(center.X, center.Y, center.Z, size.X*1.2), WithDeferredMerge()),
+		corners: map[*Node]*[8]float64{},
+	}
+	// The corners are shared by the neighbour cells, the distances are cached
+	cache := map[[3]float64]float64{}
+	distance := func(p vector3.Vector3) float64 {
+		key := [3]float64{p.X, p.Y, p.Z}
+		if d, ok := cache[key]; ok {
+			return d
+		}
+		closest, ok := m.ClosestPoint(p)
+		d := math.Inf(1)
+		if ok {
+			d = closest.Distance
+			if m.Inside(p) {
+				d = -d
+			}
+		}
+		cache[key] = d
+		return d
+	}
+	s.build(s.tree.root, maxDepth, errorTolerance, distance)
+	return s
+}
+
+// Region returns the region covered by the field
+func (s *SDF) Region() volume.Box {
+	return cloneBox(s.tree.root.region)
+}
+
+// Cells returns the regions of the cells of the field, in the DFS order of the leaves of its octree.
+// The octree itself isn't exposed, inserting in it would create cells without distances
+func (s *SDF) Cells() []volume.Box {
+	leaves := s.tree.Leaves()
+	cells := make([]volume.Box, len(leaves))
+	for i, leaf := range leaves {
+		cells[i] = cloneBox(leaf.region)
+	}
+	return cells
+}
+
+// cloneBox returns a copy of b not sharing its vectors
+func cloneBox(b volume.Box) volume.Box {
+	return volume.Box{Min: b.Min.Clone(), Max: b.Max.Clone()}
+}
+
+func (s *SDF) build(n *Node, maxDepth int, errorTolerance float64, distance func(vector3.Vector3) float64) {
+	var corners [8]float64
+	for i := range corners {
+		corners[i] = distance(cellPoint(n.region, float64(i>>2&1), float64(i>>1&1), float64(i&1)))
+	}
+	if n.depth < maxDepth && !linear(n.region, &corners, errorTolerance, distance) {
+		n.split()
+		for i := range n.children {
+			s.build(&n.children[i], maxDepth, errorTolerance, distance)
+		}
+		return
+	}
+	s.corners[n] = &corners
+}
+
+// linear returns whether the trilinear interpolation of corners is within errorTolerance of distance
+// on the 3x3x3 lattice of the cell, the corners of its children
+func linear(region volume.Box, corners *[8]float64, errorTolerance float64, distance func(vector3.Vector3) float64) bool {
+	for _, u := range [3]float64{0, 0.5, 1} {
+		for _, v := range [3]float64{0, 0.5, 1} {
+			for _, w := range [3]float64{0, 0.5, 1} {
+				if u != 0.5 && v != 0.5 && w != 0.5 {
+					// A corner
+					continue
+				}
+				if math.Abs(trilinear(corners, u, v, w)-distance(cellPoint(region, u, v, w))) > errorTolerance {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// cellPoint returns the point of region at the relative coordinates u, v, w in [0, 1]
+func cellPoint(region volume.Box, u, v, w float64) vector3.Vector3 {
+	return *vector3.NewVector3(
+		region.Min.X+(region.Max.X-region.Min.X)*u,
+		region.Min.Y+(region.Max.Y-region.Min.Y)*v,
+		region.Min.Z+(region.Max.Z-region.Min.Z)*w,
+	)
+}
+
+func trilinear(c *[8]float64, u, v, w float64) float64 {
+	lerp := func(a, b, t float64) float64 { return a + (b-a)*t }
+	return lerp(
+		lerp(lerp(c[0], c[1], w), lerp(c[2], c[3], w), v),
+		lerp(lerp(c[4], c[5], w), lerp(c[6], c[7], w), v),
+		u,
+	)
+}
+
+// locate returns the leaf containing the point of the region closest to p, the relative coordinates
+// of this point in the leaf and the distance between p and the region
+func (s *SDF) locate(p vector3.Vector3) (*Node, float64, float64, float64, float64) {
+	region := s.tree.root.region
+	clamped := vector3.Max(*region.Min, vector3.Min(*region.Max, p))
+	leaf := s.tree.LeafAt(clamped)
+	r := leaf.region
+	u := (clamped.X - r.Min.X) / (r.Max.X - r.Min.X)
+	v := (clamped.Y - r.Min.Y) / (r.Max.Y - r.Min.Y)
+	w := (clamped.Z - r.Min.Z) / (r.Max.Z - r.Min.Z)
+	return leaf, u, v, w, clamped.Distance(p)
+}
+
+// SampleDistance returns the interpolated signed distance at p, negative inside the mesh.
+// Outside the region of the field, the distance to the region is added to the one at its closest point
+func (s *SDF) SampleDistance(p vector3.Vector3) float64 {
+	leaf, u, v, w, outside := s.locate(p)
+	return trilinear(s.corners[leaf], u, v, w) + outside
+}
+
+// SampleGradient returns the gradient of the interpolated distance at p, pointing away from the surface outside
+// the mesh and toward it inside, of length about 1. Outside the region of the field, it is the one at its closest point
+func (s *SDF) SampleGradient(p vector3.Vector3) vector3.Vector3 {
+	leaf, u, v, w, _ := s.locate(p)
+	c := s.corners[leaf]
+	size := leaf.region.GetSize()
+	// Partial derivatives of the trilinear interpolation
+	du := trilinear(&[8]float64{c[4] - c[0], c[5] - c[1], c[6] - c[2], c[7] - c[3], c[4] - c[0], c[5] - c[1], c[6] - c[2], c[7] - c[3]}, 0, v, w)
+	dv := trilinear(&[8]float64{c[2] - c[0], c[3] - c[1], c[2] - c[0], c[3] - c[1], c[6] - c[4], c[7] - c[5], c[6] - c[4], c[7] - c[5]}, u, 0, w)
+	dw := trilinear(&[8]float64{c[1] - c[0], c[1] - c[0], c[3] - c[2], c[3] - c[2], c[5] - c[4], c[5] - c[4], c[7] - c[6], c[7] - c[6]}, u, v, 0)
+	return *vector3.NewVector3(du/size.X, dv/size.Y, dw/size.Z)
+}
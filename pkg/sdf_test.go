@@ -0,0 +1,48 @@
+package octree
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/louis030195/protometry/api/vector3"
+	"github.com/louis030195/protometry/api/volume"
+)
+
+// cubeDistance is the exact signed distance to the cube of side 2 centered at the origin
+func cubeDistance(p vector3.Vector3) float64 {
+	q := *vector3.NewVector3(math.Abs(p.X)-1, math.Abs(p.Y)-1, math.Abs(p.Z)-1)
+	outside := vector3.Max(q, vector3.Vector3{}).Norm2()
+	return outside + math.Min(math.Max(q.X, math.Max(q.Y, q.Z)), 0)
+}
+
+func TestBuildSDF(t *testing.T) {
+	s := BuildSDF(*volume.NewMeshSquareCuboid(2, true), 5, 0.01)
+	equals(t, nil, s.tree.Validate())
+	cells := s.Cells()
+	equals(t, len(cells), len(s.corners))
+	// Adaptive, far from the 8^5 cells of a full grid
+	equals(t, true, len(cells) < 10000)
+	equals(t, true, s.tree.getHeight() == 6)
+	// Copies, the field can't be modified through them
+	cells[0].Min.X = 100
+	equals(t, true, s.Cells()[0].Min.Equal(*s.Region().Min))
+
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		p := *vector3.NewVector3(r.Float64()*3-1.5, r.Float64()*3-1.5, r.Float64()*3-1.5)
+		equals(t, true, math.Abs(s.SampleDistance(p)-cubeDistance(p)) < 0.05)
+	}
+	equals(t, true, math.Abs(s.SampleDistance(*vector3.NewVector3(0, 0, 0))+1) < 0.01)
+	// Outside the field
+	equals(t, true, math.Abs(s.SampleDistance(*vector3.NewVector3(10, 0.5, 0))-9) < 0.01)
+
+	for _, c := range []struct{ point, gradient vector3.Vector3 }{
+		{*vector3.NewVector3(1.3, 0.1, 0.2), *vector3.NewVector3(1, 0, 0)},
+		{*vector3.NewVector3(0.1, -0.8, 0.2), *vector3.NewVector3(0, -1, 0)},
+		{*vector3.NewVector3(0.2, 0.1, 1.1), *vector3.NewVector3(0, 0, 1)},
+	} {
+		g := s.SampleGradient(c.point)
+		equals(t, true, g.Minus(c.gradient).Norm2() < 0.05)
+	}
+}
@@ -0,0 +1,91 @@
+package octree
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"github.com/louis030195/protometry/api/volume"
+)
+
+// snapshotVersion is bumped whenever the snapshot format changes
+const snapshotVersion = 3
+
+// snapshot is the gob encoded content written by Save
+type snapshot struct {
+	Version int
+	Region  [6]float64
+	// SplitThreshold appeared in version 3
+	SplitThreshold int
+	Objects        []snapshotObject
+}
+
+type snapshotObject struct {
+	ID     uint64
+	Bounds [6]float64
+	Data   interface{}
+	// Layers appeared in version 2
+	Layers uint64
+}
+
+// Save writes the region, the split threshold and the objects of the Octree to w.
+// Object data is gob encoded, concrete types other than the basic ones must be registered with gob.Register.
+func (o *Octree) Save(w io.Writer) error {
+	s := snapshot{Version: snapshotVersion, Region: boxToArray(o.root.region), SplitThreshold: o.getSplitThreshold()}
+	o.Range(func(object *Object) bool {
+		s.Objects = append(s.Objects, snapshotObject{
+			ID:     object.id,
+			Bounds: boxToArray(object.Bounds),
+			Data:   object.Data,
+			Layers: object.Layers,
+		})
+		return true
+	})
+	return gob.NewEncoder(w).Encode(s)
+}
+
+// Load reads an Octree written by Save, objects keep their ID and the tree its split threshold.
+// The options are applied to the new tree as with NewOctree, after the split threshold of the snapshot
+func Load(r io.Reader, opts ...Option) (*Octree, error) {
+	var s snapshot
+	if err := gob.NewDecoder(r).Decode(&s); err != nil {
+		return nil, err
+	}
+	if s.Version < 1 || s.Version > snapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %v", s.Version)
+	}
+	if s.SplitThreshold > 0 {
+		opts = append([]Option{WithSplitThreshold(s.SplitThreshold)}, opts...)
+	}
+	o := NewOctree(arrayToBox(s.Region), opts...)
+	for _, so := range s.Objects {
+		object := Object{id: so.ID, Data: so.Data, Bounds: *arrayToBox(so.Bounds), Layers: so.Layers}
+		if s.Version < 2 {
+			object.Layers = DefaultLayers
+		}
+		if !o.Insert(object) {
+			return nil, fmt.Errorf("object %v doesn't fit in the snapshot region", so.ID)
+		}
+		reserveID(so.ID)
+	}
+	return o, nil
+}
+
+// reserveID makes sure newID won't return id or anything below
+func reserveID(id uint64) {
+	for {
+		current := atomic.LoadUint64(&idInc)
+		if current >= id || atomic.CompareAndSwapUint64(&idInc, current, id) {
+			return
+		}
+	}
+}
+
+func boxToArray(b volume.Box) [6]float64 {
+	return [6]float64{b.Min.X, b.Min.Y, b.Min.Z, b.Max.X, b.Max.Y, b.Max.Z}
+}
+
+func arrayToBox(a [6]float64) *volume.Box {
+	return volume.NewBoxMinMax(a[0], a[1], a[2], a[3], a[4], a[5])
+}
@@ -0,0 +1,80 @@
+package octree
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+
+	"github.com/louis030195/protometry/api/volume"
+)
+
+func TestOctree_SaveLoad(t *testing.T) {
+	size := 100.
+	o := octreeRandomInsertions(t, size)
+	equals(t, true, o.Insert(*NewObjectCube("data", 0, 0, 0, 1)))
+	layered := NewObjectCube("layered", 1, 1, 1, 1)
+	layered.Layers = 6
+	equals(t, true, o.Insert(*layered))
+
+	var b bytes.Buffer
+	equals(t, nil, o.Save(&b))
+	loaded, err := Load(&b)
+	equals(t, nil, err)
+	equals(t, true, o.root.region.Equal(loaded.root.region))
+	equals(t, o.getNumberOfObjects(), loaded.getNumberOfObjects())
+
+	// Same objects, same ids
+	for _, obj := range o.GetAllObjects() {
+		found := loaded.Get(obj.ID(), obj.Bounds)
+		equals(t, true, found != nil)
+		equals(t, obj.Data, found.Data)
+		equals(t, true, obj.Bounds.Equal(found.Bounds))
+		equals(t, obj.Layers, found.Layers)
+	}
+	equals(t, nil, loaded.Validate())
+	// New ids don't collide with loaded ones
+	max := uint64(0)
+	loaded.Range(func(object *Object) bool {
+		if object.ID() > max {
+			max = object.ID()
+		}
+		return true
+	})
+	equals(t, true, NewObject(nil, volume.Box{}).ID() > max)
+
+	_, err = Load(bytes.NewBufferString("not a snapshot"))
+	equals(t, true, err != nil)
+}
+
+func TestLoad_SplitThreshold(t *testing.T) {
+	o := NewOctree(volume.NewBoxOfSize(0, 0, 0, 8), WithSplitThreshold(2))
+	var b bytes.Buffer
+	equals(t, nil, o.Save(&b))
+	saved := b.Bytes()
+	o, err := Load(bytes.NewReader(saved))
+	equals(t, nil, err)
+	equals(t, 2, o.getSplitThreshold())
+	// The options come after the snapshot
+	o, err = Load(bytes.NewReader(saved), WithSplitThreshold(3))
+	equals(t, nil, err)
+	equals(t, 3, o.getSplitThreshold())
+}
+
+func TestLoad_Version1(t *testing.T) {
+	// Version 1 snapshots have no layers
+	var b bytes.Buffer
+	equals(t, nil, gob.NewEncoder(&b).Encode(snapshot{
+		Version: 1,
+		Region:  boxToArray(*volume.NewBoxOfSize(0, 0, 0, 8)),
+		Objects: []snapshotObject{{ID: 1, Bounds: boxToArray(*volume.NewBoxOfSize(0, 0, 0, 1))}},
+	}))
+	o, err := Load(&b)
+	equals(t, nil, err)
+	equals(t, DefaultLayers, o.GetAllObjects()[0].Layers)
+	equals(t, CAPACITY, o.getSplitThreshold())
+
+	b.Reset()
+	equals(t, nil, gob.NewEncoder(&b).Encode(snapshot{Version: snapshotVersion + 1}))
+	_, err = Load(&b)
+	equals(t, true, err != nil)
+}
@@ -0,0 +1,71 @@
+package octree
+
+import (
+	"unsafe"
+
+	"github.com/louis030195/protometry/api/vector3"
+)
+
+// Stats describes the shape of a tree, e.g. to tune the split and merge thresholds
+type Stats struct {
+	// Height is the number of levels, 1 for a tree without children
+	Height int
+	// Nodes is the total number of nodes, the root included
+	Nodes int
+	// Leaves is the number of nodes without children, EmptyLeaves the ones without objects
+	Leaves      int
+	EmptyLeaves int
+	Objects     int
+	// NodesPerDepth and ObjectsPerDepth are indexed by depth, the root being at depth 0
+	NodesPerDepth   []int
+	ObjectsPerDepth []int
+	// MaxObjects is the largest number of objects held by a single node
+	MaxObjects int
+	// Straddling is the number of objects held by nodes with children,
+	// they are stuck there because they cross the boundaries of the children
+	Straddling int
+	// Usage is the ratio of objects over the total capacity of the nodes
+	Usage float64
+	// Bytes is an estimation of the memory used by the nodes and the objects,
+	// the values referenced by the objects Data aren't counted
+	Bytes int
+}
+
+var (
+	nodeBytes   = int(unsafe.Sizeof(Node{}))
+	objectBytes = int(unsafe.Sizeof(Object{}))
+	// Bounds min and max are pointers
+	boundsBytes = 2 * int(unsafe.Sizeof(vector3.Vector3{}))
+)
+
+// Stats walks the whole tree and returns its statistics
+func (o *Octree) Stats() Stats {
+	var s Stats
+	s.Bytes = int(unsafe.Sizeof(*o))
+	o.root.walk(0, func(n *Node, depth int) bool {
+		if depth == len(s.NodesPerDepth) {
+			s.NodesPerDepth = append(s.NodesPerDepth, 0)
+			s.ObjectsPerDepth = append(s.ObjectsPerDepth, 0)
+		}
+		s.NodesPerDepth[depth]++
+		s.ObjectsPerDepth[depth] += len(n.objects)
+		s.Nodes++
+		s.Objects += len(n.objects)
+		if len(n.objects) > s.MaxObjects {
+			s.MaxObjects = len(n.objects)
+		}
+		if n.children == nil {
+			s.Leaves++
+			if len(n.objects) == 0 {
+				s.EmptyLeaves++
+			}
+		} else {
+			s.Straddling += len(n.objects)
+		}
+		s.Bytes += nodeBytes + cap(n.objects)*objectBytes + len(n.objects)*boundsBytes
+		return true
+	})
+	s.Height = len(s.NodesPerDepth)
+	s.Usage = float64(s.Objects) / float64(s.Nodes*o.getSplitThreshold())
+	return s
+}
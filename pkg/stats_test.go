@@ -0,0 +1,36 @@
+package octree
+
+import (
+	"testing"
+
+	"github.com/louis030195/protometry/api/volume"
+)
+
+func TestOctree_Stats(t *testing.T) {
+	o := NewOctree(volume.NewBoxOfSize(0, 0, 0, 8))
+	s := o.Stats()
+	equals(t, 1, s.Height)
+	equals(t, 1, s.Nodes)
+	equals(t, 1, s.EmptyLeaves)
+	equals(t, 0, s.Objects)
+
+	for i := 0; i < CAPACITY+1; i++ {
+		equals(t, true, o.Insert(*NewObjectCube(i, 2, 2, 2, 1)))
+	}
+	// Straddling the center of the tree
+	equals(t, true, o.Insert(*NewObjectCube(0, 0, 0, 0, 1)))
+
+	s = o.Stats()
+	equals(t, 3, s.Height)
+	equals(t, 17, s.Nodes)
+	equals(t, o.getNumberOfNodes(), s.Nodes)
+	equals(t, 15, s.Leaves)
+	equals(t, 15, s.EmptyLeaves)
+	equals(t, CAPACITY+2, s.Objects)
+	equals(t, []int{1, 8, 8}, s.NodesPerDepth)
+	equals(t, []int{1, CAPACITY + 1, 0}, s.ObjectsPerDepth)
+	equals(t, CAPACITY+1, s.MaxObjects)
+	equals(t, CAPACITY+2, s.Straddling)
+	equals(t, o.getUsage(), s.Usage)
+	equals(t, true, s.Bytes > 17*nodeBytes+(CAPACITY+2)*(objectBytes+boundsBytes))
+}
@@ -0,0 +1,83 @@
+package octree
+
+import (
+	"sort"
+	"time"
+
+	"github.com/louis030195/protometry/api/vector3"
+	"github.com/louis030195/protometry/api/volume"
+)
+
+// SweepHit is an object hit by a moving box
+type SweepHit struct {
+	Object Object
+	// Time of impact in [0, 1], the fraction of the displacement done when the boxes start touching
+	Time float64
+}
+
+// Sweep returns the objects hit by box moving by displacement, sorted by time of impact,
+// so fast moving objects can't tunnel through thin ones as when only their end position is checked.
+// The objects intersecting box before moving are hit at time 0
+func (o *Octree) Sweep(box volume.Box, displacement vector3.Vector3) []SweepHit {
+	return o.SweepMask(box, displacement, AllLayers)
+}
+
+// SweepMask is Sweep restricted to the objects sharing a layer with mask
+func (o *Octree) SweepMask(box volume.Box, displacement vector3.Vector3, mask uint64) []SweepHit {
+	if o.metrics != nil {
+		defer o.metrics.observe(querySweep, time.Now())
+	}
+	s := newSweep(box, displacement, mask)
+	hits := o.root.sweep(s, nil)
+	sort.SliceStable(hits, func(i, j int) bool {
+		return hits[i].Time < hits[j].Time
+	})
+	return hits
+}
+
+// sweep is a box moving along a segment, it is tested against the other boxes as the ray going from its center
+// by displacement against these boxes grown by its half size, their Minkowski sum
+type sweep struct {
+	ray      ray
+	halfSize vector3.Vector3
+	// bounds covers the box along the whole segment, the swept AABB
+	bounds volume.Box
+}
+
+func newSweep(box volume.Box, displacement vector3.Vector3, mask uint64) sweep {
+	min, max := box.Min.Plus(displacement), box.Max.Plus(displacement)
+	min, max = vector3.Min(min, *box.Min), vector3.Max(max, *box.Max)
+	bounds := volume.Box{Min: &min, Max: &max}
+	size := box.GetSize()
+	s := sweep{ray: newRay(box.GetCenter(), displacement, 1), halfSize: size.Times(0.5), bounds: bounds}
+	s.ray.mask = mask
+	return s
+}
+
+// timeOfImpact returns when the moving box starts touching b
+func (s sweep) timeOfImpact(b volume.Box) (float64, bool) {
+	min, max := b.Min.Minus(s.halfSize), b.Max.Plus(s.halfSize)
+	grown := volume.Box{Min: &min, Max: &max}
+	return s.ray.intersects(grown)
+}
+
+func (n *Node) sweep(s sweep, hits []SweepHit) []SweepHit {
+	n.metrics().add(countVisits)
+	if !matchLayers(n.layers, s.ray.mask) || !n.region.Intersects(s.bounds) {
+		return hits
+	}
+	for _, obj := range n.objects {
+		if !matchLayers(obj.Layers, s.ray.mask) || !obj.Bounds.Intersects(s.bounds) {
+			continue
+		}
+		if t, ok := s.timeOfImpact(obj.Bounds); ok {
+			hits = append(hits, SweepHit{Object: obj, Time: t})
+		}
+	}
+	if n.children != nil {
+		for i := range n.children {
+			hits = n.children[i].sweep(s, hits)
+		}
+	}
+	return hits
+}
@@ -0,0 +1,49 @@
+package octree
+
+import (
+	"testing"
+
+	"github.com/louis030195/protometry/api/vector3"
+	"github.com/louis030195/protometry/api/volume"
+)
+
+func TestOctree_Sweep(t *testing.T) {
+	o := NewOctree(volume.NewBoxOfSize(0, 0, 0, 100))
+	wall := NewObject("wall", *volume.NewBoxMinMax(9.9, -10, -10, 10.1, 10, 10))
+	equals(t, true, o.Insert(*wall))
+	equals(t, true, o.Insert(*NewObjectCube("start", 0, 0, 0, 1)))
+	equals(t, true, o.Insert(*NewObjectCube("behind", 15, 0, 0, 1)))
+	equals(t, true, o.Insert(*NewObjectCube("aside", 20, -20, 0, 1)))
+
+	projectile := *volume.NewBoxOfSize(0, 0, 0, 1)
+	displacement := *vector3.NewVector3(20, 0, 0)
+	// The end position is past the wall
+	end := *volume.NewBoxOfSize(20, 0, 0, 1)
+	equals(t, 0, len(o.GetColliding(end)))
+
+	hits := o.Sweep(projectile, displacement)
+	equals(t, 3, len(hits))
+	equals(t, "start", hits[0].Object.Data)
+	equals(t, 0., hits[0].Time)
+	equals(t, "wall", hits[1].Object.Data)
+	equals(t, true, hits[1].Time > 0.469 && hits[1].Time < 0.471)
+	equals(t, "behind", hits[2].Object.Data)
+	equals(t, true, hits[2].Time > 0.699 && hits[2].Time < 0.701)
+
+	// start is inside the swept AABB but away from the path
+	hits = o.Sweep(*volume.NewBoxOfSize(0, 20, 0, 1), *vector3.NewVector3(20, -20, 0))
+	equals(t, 1, len(hits))
+	equals(t, "wall", hits[0].Object.Data)
+
+	// Not moving, the objects touching the box
+	hits = o.Sweep(projectile, vector3.Vector3{})
+	equals(t, 1, len(hits))
+	equals(t, "start", hits[0].Object.Data)
+
+	wall.Layers = 2
+	equals(t, true, o.Remove(*wall))
+	equals(t, true, o.Insert(*wall))
+	hits = o.SweepMask(projectile, displacement, 2)
+	equals(t, 1, len(hits))
+	equals(t, "wall", hits[0].Object.Data)
+}
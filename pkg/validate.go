@@ -0,0 +1,113 @@
+package octree
+
+import (
+	"fmt"
+
+	"github.com/louis030195/protometry/api/volume"
+)
+
+// ValidationError is a broken invariant of the tree, Region and Depth locate the offending node
+type ValidationError struct {
+	Region volume.Box
+	Depth  int
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("node %v at depth %v: %v", formatRegion(e.Region), e.Depth, e.Reason)
+}
+
+// Validate checks the structure of the tree and returns a *ValidationError on the first broken invariant,
+// e.g. an object outside its node region, a duplicate id or layers and counts not summarizing the subtree.
+// It is meant for tests and debugging, e.g. after modifying objects bounds in place.
+func (o *Octree) Validate() error {
+	var err error
+	seen := map[uint64]volume.Box{}
+	o.root.walk(0, func(n *Node, depth int) bool {
+		if err != nil {
+			return false
+		}
+		fail := func(format string, args ...interface{}) bool {
+			err = &ValidationError{Region: n.region, Depth: depth, Reason: fmt.Sprintf(format, args...)}
+			return false
+		}
+		for _, obj := range n.objects {
+			if !obj.Bounds.Fit(n.region) {
+				return fail("object %v %v doesn't fit in the node", obj.ID(), formatRegion(obj.Bounds))
+			}
+			if region, ok := seen[obj.ID()]; ok {
+				return fail("object %v is also in node %v", obj.ID(), formatRegion(region))
+			}
+			seen[obj.ID()] = n.region
+		}
+		// The layers and counts summarizing the subtree, checked last as a node modified in place
+		// usually breaks them along with the other invariants
+		checkSummaries := func() bool {
+			for _, obj := range n.objects {
+				if obj.Layers&^n.layers != 0 {
+					return fail("object %v layers %#x are missing from the node layers %#x", obj.ID(), obj.Layers, n.layers)
+				}
+			}
+			if n.children != nil {
+				for i := range n.children {
+					if layers := n.children[i].layers; layers&^n.layers != 0 {
+						return fail("child %v layers %#x are missing from the node layers %#x", i, layers, n.layers)
+					}
+				}
+			}
+			for i, a := range o.getAggregators() {
+				if _, ok := a.(CountAggregator); !ok {
+					continue
+				}
+				if count, objects := n.aggregates[i].(int), n.getNumberOfObjects(); count != objects {
+					return fail("count aggregate %v should be %v objects", count, objects)
+				}
+			}
+			return true
+		}
+		if n.children == nil {
+			if split := o.getSplitThreshold(); len(n.objects) > split {
+				return fail("leaf holds %v objects, more than the split threshold %v", len(n.objects), split)
+			}
+			if o.balance21 {
+				for _, d := range Directions {
+					for _, neighbor := range o.Neighbors(n, d) {
+						if neighbor.depth < depth-1 {
+							return fail("neighbour %v at depth %v breaks the 2:1 balance", formatRegion(neighbor.region), neighbor.depth)
+						}
+					}
+				}
+			}
+			return checkSummaries()
+		}
+
+		subBoxes := n.region.Split()
+		mergeable := len(n.objects)
+		for i := range n.children {
+			c := &n.children[i]
+			if !c.region.Equal(*subBoxes[i]) {
+				return fail("child %v region %v should be %v", i, formatRegion(c.region), formatRegion(*subBoxes[i]))
+			}
+			if c.parent != n || c.depth != depth+1 {
+				return fail("child %v is not linked to its parent", i)
+			}
+			if mergeable >= 0 && c.children == nil {
+				mergeable += len(c.objects)
+			} else {
+				mergeable = -1
+			}
+		}
+		for _, obj := range n.objects {
+			for i := range n.children {
+				if obj.Bounds.Fit(n.children[i].region) {
+					return fail("object %v fits in child %v", obj.ID(), i)
+				}
+			}
+		}
+		if mergeable >= 0 && mergeable <= o.getMergeThreshold() && !o.deferredMerge && !o.balance21 {
+			return fail("children hold %v objects and should have been merged", mergeable)
+		}
+		return checkSummaries()
+	})
+	return err
+}
@@ -0,0 +1,87 @@
+package octree
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/louis030195/protometry/api/volume"
+)
+
+func validationError(t *testing.T, o *Octree, reason string) {
+	t.Helper()
+	err := o.Validate()
+	if err == nil || !strings.Contains(err.Error(), reason) {
+		t.Fatalf("expected %q, got %v", reason, err)
+	}
+}
+
+func TestOctree_Validate(t *testing.T) {
+	o := NewOctree(volume.NewBoxOfSize(0, 0, 0, 8))
+	objects := make([]*Object, CAPACITY+1)
+	for i := range objects {
+		objects[i] = NewObjectCube(i, 2, 2, 2, 1)
+		equals(t, true, o.Insert(*objects[i]))
+	}
+	equals(t, nil, o.Validate())
+	for _, obj := range objects[1:] {
+		equals(t, true, o.Remove(*obj))
+		equals(t, nil, o.Validate())
+	}
+
+	// Bounds modified in place, the copy in the tree shares them
+	objects[0].Bounds.Max.X = 10
+	validationError(t, o, "doesn't fit in the node")
+	err := o.Validate().(*ValidationError)
+	equals(t, 0, err.Depth)
+	objects[0].Bounds.Max.X = 2.5
+
+	o.root.objects = append(o.root.objects, o.root.objects[0])
+	validationError(t, o, "is also in node")
+
+	o = NewOctree(volume.NewBoxOfSize(0, 0, 0, 8))
+	for i := 0; i < CAPACITY+2; i++ {
+		equals(t, true, o.Insert(*NewObjectCube(i, 2, 2, 2, 1)))
+	}
+	equals(t, nil, o.Validate())
+	// The objects are at depth 1, in a node with children
+	child := &o.root.children[7]
+	child.objects = append(child.objects, *NewObjectCube(0, 1, 1, 1, 1))
+	validationError(t, o, "fits in child 0")
+	err = o.Validate().(*ValidationError)
+	equals(t, 1, err.Depth)
+	equals(t, true, err.Region.Equal(*volume.NewBoxMinMax(0, 0, 0, 4, 4, 4)))
+	child.objects = child.objects[:len(child.objects)-1]
+
+	child.children[0].objects = make([]Object, CAPACITY+1)
+	for i := range child.children[0].objects {
+		child.children[0].objects[i] = *NewObjectCube(0, 1, 1, 1, 1)
+	}
+	validationError(t, o, "more than the split threshold")
+	child.children[0].objects = nil
+
+	child.children[1].region = child.children[0].region
+	validationError(t, o, "child 1 region")
+	child.children[1].region = *child.region.Split()[1]
+	equals(t, nil, o.Validate())
+
+	child.objects[0].Layers = 2
+	validationError(t, o, "layers 0x2 are missing")
+	child.layers |= 2
+	validationError(t, o, "child 7 layers 0x3 are missing")
+	child.objects[0].Layers = DefaultLayers
+	child.layers = DefaultLayers
+	equals(t, nil, o.Validate())
+
+	o.root.objects = o.root.objects[:0]
+	child.objects = child.objects[:1]
+	validationError(t, o, "should have been merged")
+
+	// The count aggregates summarize the subtrees
+	o = NewOctree(volume.NewBoxOfSize(0, 0, 0, 8), WithAggregator(CountAggregator{}))
+	for i := 0; i < CAPACITY+2; i++ {
+		equals(t, true, o.Insert(*NewObjectCube(i, 2, 2, 2, 1)))
+	}
+	equals(t, nil, o.Validate())
+	o.root.children[7].aggregates[0] = 3
+	validationError(t, o, "count aggregate 3 should be 7 objects")
+}